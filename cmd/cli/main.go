@@ -13,6 +13,7 @@ limitations under the License.
 package main
 
 import (
+	"bytes"
 	"context"
 	"crypto/tls"
 	"crypto/x509"
@@ -52,6 +53,9 @@ var (
 	tlsCA          = flag.String("tls.CA", "/certs/CA.crt", "CA certificate")
 	tlsClientCrt   = flag.String("tls.Crt", "/certs/envoy.crt", "tls client certificate")
 	tlsClientKey   = flag.String("tls.Key", "/certs/envoy.key", "tls client certificate key")
+	dryRun         = flag.String("dryRun", "", "path to ConfigMap to show changes without applying")
+	adminUser      = flag.String("admin.user", "admin", "controlplane admin user")
+	adminPassword  = flag.String("admin.password", os.Getenv("ADMIN_PASSWORD"), "controlplane admin password")
 )
 
 func waitForAPI() {
@@ -113,6 +117,41 @@ func requestEnvoyAdmin(path string) {
 	log.Printf("[%s] %s", path, string(body))
 }
 
+// post ConfigMap to controlplane and print changes.
+func requestDryRun(path string) {
+	configMap, err := os.ReadFile(path)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	requestURL := fmt.Sprintf("https://%s:%d/api/admin/dry_run?namespace=%s", *server, *port, url.QueryEscape(*namespace)) //nolint:nosprintfhostport,lll
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, requestURL, bytes.NewReader(configMap))
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	req.SetBasicAuth(*adminUser, *adminPassword)
+	req.Header.Set("Content-Type", "application/yaml")
+
+	resp, err := cli.Do(req)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	os.Stdout.WriteString(string(body))
+
+	if resp.StatusCode != http.StatusOK {
+		log.Fatal("result not ok")
+	}
+}
+
 func main() {
 	flag.Parse()
 
@@ -171,6 +210,16 @@ func main() {
 		return
 	}
 
+	if len(*dryRun) > 0 {
+		if *wait {
+			waitForAPI()
+		}
+
+		requestDryRun(*dryRun)
+
+		return
+	}
+
 	if len(*namespace) == 0 {
 		log.Fatal("no namespace")
 	}
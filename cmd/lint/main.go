@@ -0,0 +1,98 @@
+/*
+Copyright paskal.maksim@gmail.com
+Licensed under the Apache License, Version 2.0 (the "License")
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	core "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
+	"github.com/maksim-paskal/envoy-control-plane/pkg/config"
+	_ "github.com/maksim-paskal/envoy-control-plane/pkg/extensions"
+	"github.com/maksim-paskal/envoy-control-plane/pkg/lint"
+	log "github.com/sirupsen/logrus"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+var (
+	nodeID       = flag.String("sample.node.id", "", "node id in template data")
+	nodeCluster  = flag.String("sample.node.cluster", "", "node cluster in template data")
+	nodeZone     = flag.String("sample.node.zone", "", "node zone in template data")
+	nodeMetadata = flag.String("sample.node.metadata", "", "node metadata in template data, format key1=value1,key2=value2") //nolint:lll
+	static       = flag.String("static.clusters", "", "comma separated clusters from envoy bootstrap config")
+)
+
+// sample envoy node for per node templates.
+func getSampleNode() *core.Node {
+	node := &core.Node{
+		Id:       *nodeID,
+		Cluster:  *nodeCluster,
+		Locality: &core.Locality{Zone: *nodeZone},
+		Metadata: &structpb.Struct{Fields: make(map[string]*structpb.Value)},
+	}
+
+	for _, item := range strings.Split(*nodeMetadata, ",") {
+		keyAndValue := strings.SplitN(strings.TrimSpace(item), "=", 2) //nolint:gomnd
+		if len(keyAndValue) != 2 {                                     //nolint:gomnd
+			continue
+		}
+
+		node.Metadata.Fields[keyAndValue[0]] = structpb.NewStringValue(keyAndValue[1])
+	}
+
+	return node
+}
+
+func main() {
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage: %s [flags] files...\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+
+	flag.Parse()
+
+	if flag.NArg() == 0 {
+		flag.Usage()
+		os.Exit(2) //nolint:gomnd
+	}
+
+	// resources errors are reported as lint issues
+	log.SetLevel(log.FatalLevel)
+
+	linter := lint.New(config.NewTemplateData(getSampleNode()))
+
+	if len(*static) > 0 {
+		linter.AddStaticClusters(strings.Split(*static, ",")...)
+	}
+
+	// fragments from all files must be loaded before lint
+	for _, file := range flag.Args() {
+		if err := linter.AddFile(file); err != nil {
+			log.SetLevel(log.InfoLevel)
+			log.WithError(err).Fatal(file)
+		}
+	}
+
+	issues := linter.Lint()
+
+	for _, issue := range issues {
+		fmt.Println(issue.String()) //nolint:forbidigo
+	}
+
+	if len(issues) > 0 {
+		fmt.Printf("%d issues found\n", len(issues)) //nolint:forbidigo
+		os.Exit(1)
+	}
+}
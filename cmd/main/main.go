@@ -25,6 +25,8 @@ import (
 	"github.com/maksim-paskal/envoy-control-plane/pkg/api"
 	"github.com/maksim-paskal/envoy-control-plane/pkg/config"
 	"github.com/maksim-paskal/envoy-control-plane/pkg/controlplane"
+	_ "github.com/maksim-paskal/envoy-control-plane/pkg/extensions"
+	"github.com/maksim-paskal/envoy-control-plane/pkg/lint"
 	"github.com/maksim-paskal/envoy-control-plane/pkg/metrics"
 	"github.com/maksim-paskal/envoy-control-plane/pkg/web"
 	log "github.com/sirupsen/logrus"
@@ -54,10 +56,20 @@ func main() {
 
 	if *validate != "" {
 		// validate config file
-		if err := config.ValidateConfig(*validate); err != nil {
+		linter := lint.New(config.NewTemplateData(nil))
+
+		if err := linter.AddFile(*validate); err != nil {
 			log.Fatal(err)
 		}
 
+		if issues := linter.Lint(); len(issues) > 0 {
+			for _, issue := range issues {
+				log.Error(issue.String())
+			}
+
+			log.Fatalf("%s has %d issues", *validate, len(issues))
+		}
+
 		log.Infof("%s OK", *validate)
 		os.Exit(0)
 	}
@@ -96,14 +108,29 @@ func main() {
 	// start metrics server
 	go web.Start(ctx)
 
-	if *config.Get().LeaderElection {
-		// run leader election
-		RunLeaderElection(ctx)
-	} else {
+	// init controlplane, restored snapshots are served before kubernetes informers are synced
+	controlplane.Init(ctx)
+
+	switch {
+	case !*config.Get().LeaderElection:
 		// run as a single instance
-		start(ctx)
+		api.SetLeader(true)
+
+		go controlplane.Start(ctx)
+	case *config.Get().ActiveActive:
+		// every replica serves xDS from own snapshot cache,
+		// leader election is used only for statuses and events
+		go controlplane.Start(ctx)
+
+		RunLeaderElection(ctx)
+	default:
+		// only leader serves xDS
+		RunLeaderElection(ctx)
 	}
 
+	// all replicas watch kubernetes
+	start(ctx)
+
 	<-ctx.Done()
 
 	log.Info("Stoped...")
@@ -114,11 +141,6 @@ func main() {
 func start(ctx context.Context) {
 	internal.Start(ctx)
 
-	// start controlplane
-	controlplane.Init(ctx)
-
-	go controlplane.Start(ctx)
-
 	go web.StartTLS(ctx)
 }
 
@@ -133,22 +155,41 @@ func RunLeaderElection(ctx context.Context) {
 
 	lock := GetLeaseLock(*config.Get().Namespace, *config.Get().PodName)
 
-	go leaderelection.RunOrDie(ctx, leaderelection.LeaderElectionConfig{
+	leaderElectionConfig := leaderelection.LeaderElectionConfig{
 		Lock:            lock,
 		ReleaseOnCancel: true,
 		LeaseDuration:   defaultLeaseDuration,
 		RenewDeadline:   defaultRenewDeadline,
 		RetryPeriod:     defaultRetryPeriod,
 		Callbacks: leaderelection.LeaderCallbacks{
-			OnStartedLeading: func(ctx context.Context) {
-				metrics.LeaderElectionIsMaster.Set(1)
-				start(ctx)
+			OnStartedLeading: func(leaderCtx context.Context) {
+				api.SetLeader(true)
+
+				if *config.Get().ActiveActive {
+					log.Info("leader election won, writing statuses and events")
+
+					return
+				}
+
+				log.Info("leader election won, serving xDS")
+
+				// grpc server is drained when leadership lost
+				controlplane.Start(leaderCtx)
 			},
 			OnStoppedLeading: func() {
-				log.Fatal("leader election lost")
+				api.SetLeader(false)
+
+				log.Warn("leader election lost, continue as follower")
 			},
 		},
-	})
+	}
+
+	go func() {
+		// replica tries to become leader again after lease lost
+		for ctx.Err() == nil {
+			leaderelection.RunOrDie(ctx, leaderElectionConfig)
+		}
+	}()
 }
 
 func GetLeaseLock(podNamespace string, podName string) *resourcelock.LeaseLock {
@@ -14,16 +14,24 @@ package internal
 
 import (
 	"context"
+	"strings"
 	"time"
 
+	core "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
+	"github.com/maksim-paskal/envoy-control-plane/pkg/accesslog"
 	"github.com/maksim-paskal/envoy-control-plane/pkg/api"
 	"github.com/maksim-paskal/envoy-control-plane/pkg/certs"
 	"github.com/maksim-paskal/envoy-control-plane/pkg/config"
 	"github.com/maksim-paskal/envoy-control-plane/pkg/configmapsstore"
 	"github.com/maksim-paskal/envoy-control-plane/pkg/configstore"
+	"github.com/maksim-paskal/envoy-control-plane/pkg/controlplane"
+	"github.com/maksim-paskal/envoy-control-plane/pkg/snapshotstore"
 	logrushooksentry "github.com/maksim-paskal/logrus-hook-sentry"
+	"github.com/pkg/errors"
 	log "github.com/sirupsen/logrus"
 	v1 "k8s.io/api/core/v1"
+	discoveryv1 "k8s.io/api/discovery/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 )
 
 var hook *logrushooksentry.Hook
@@ -76,6 +84,23 @@ func Init(ctx context.Context) {
 	if err = certs.Init(); err != nil {
 		log.WithError(err).Fatal()
 	}
+
+	// envoy can reconnect to any replica, certificates of all replicas must be signed by same CA
+	if certs.IsGeneratedCA() && len(*config.Get().SSLSecret) > 0 {
+		if err = loadSharedCA(ctx); err != nil {
+			log.WithError(err).Fatal()
+		}
+	}
+
+	if err = snapshotstore.Init(); err != nil {
+		log.WithError(err).Fatal()
+	}
+
+	if err = accesslog.Init(ctx); err != nil {
+		log.WithError(err).Fatal()
+	}
+
+	restoreSnapshots(ctx)
 }
 
 func Start(ctx context.Context) {
@@ -114,10 +139,20 @@ func Start(ctx context.Context) {
 	}
 
 	api.OnDeleteConfig = func(cm *v1.ConfigMap) {
-		configmapsstore.DeleteConfigMap(cm)
+		configmapsstore.DeleteConfigMap(ctx, cm)
 	}
 
-	api.OnNewEndpoints = func(endpoints *v1.Endpoints) {
+	api.OnNewEnvoyNodeConfig = func(enc *unstructured.Unstructured) {
+		if err := configmapsstore.NewEnvoyNodeConfig(ctx, enc); err != nil {
+			log.WithError(err).Error()
+		}
+	}
+
+	api.OnDeleteEnvoyNodeConfig = func(enc *unstructured.Unstructured) {
+		configmapsstore.DeleteEnvoyNodeConfig(enc)
+	}
+
+	api.OnNewEndpoints = func(endpointSlice *discoveryv1.EndpointSlice) {
 		configstore.StoreMap.Range(func(_, v interface{}) bool {
 			cs, ok := v.(*configstore.ConfigStore)
 
@@ -125,24 +160,139 @@ func Start(ctx context.Context) {
 				log.WithError(errAssertion).Fatal("OnNewEndpoints v.(*ConfigStore)")
 			}
 
-			cs.NewEndpoint(ctx, endpoints)
+			cs.NewEndpoint(ctx, endpointSlice)
 
 			return true
 		})
 	}
 
+	controlplane.OnNewNode = func(node *core.Node) {
+		if cs := getConfigStore(controlplane.ResolveConfigID(node)); cs != nil {
+			cs.AddNode(ctx, node)
+		}
+	}
+
+	controlplane.OnNodeDisconnected = func(node *core.Node) {
+		if cs := getConfigStore(controlplane.ResolveConfigID(node)); cs != nil {
+			cs.RemoveNode(ctx, node)
+		}
+	}
+
+	controlplane.OnNack = func(nodeHash, typeURL, nackError string) {
+		configID := strings.SplitN(nodeHash, "/", 2)[0] //nolint:gomnd
+
+		if cs := getConfigStore(configID); cs != nil {
+			cs.Rollback(ctx, nodeHash, typeURL, nackError)
+		}
+	}
+
+	controlplane.OnAck = configstore.MarkAcked
+
 	api.Client.RunKubeInformers(ctx)
 
 	// shedule all jobs
 	schedule(ctx)
 }
 
+func getConfigStore(nodeID string) *configstore.ConfigStore {
+	v, ok := configstore.StoreMap.Load(nodeID)
+	if !ok {
+		return nil
+	}
+
+	cs, ok := v.(*configstore.ConfigStore)
+	if !ok {
+		log.WithError(errAssertion).Fatal("getConfigStore v.(*ConfigStore)")
+	}
+
+	return cs
+}
+
 func schedule(ctx context.Context) {
 	// rotate certificates
 	go rotateCertificates(ctx)
 
 	// sync all endpoints
 	go syncAll(ctx)
+
+	// remove restored snapshots of deleted configs
+	if snapshotstore.Enabled() {
+		go reconcileSnapshots(ctx)
+	}
+
+	// write EnvoyNodeConfig status
+	if *config.Get().EnvoyNodeConfig {
+		go syncEnvoyNodeConfigStatus(ctx)
+	}
+}
+
+// nodes which snapshots were restored from snapshot store.
+var restoredNodes []string
+
+// serve last pushed snapshots before kubernetes informers are synced.
+func restoreSnapshots(ctx context.Context) {
+	snapshots, err := snapshotstore.LoadAll(ctx)
+	if err != nil {
+		log.WithError(err).Error("error loading snapshots")
+
+		return
+	}
+
+	for nodeID, snap := range snapshots {
+		if err := controlplane.SnapshotCache.SetSnapshot(ctx, nodeID, snap); err != nil {
+			log.WithError(err).Errorf("error restoring snapshot of %s", nodeID)
+
+			continue
+		}
+
+		if !strings.Contains(nodeID, "/") {
+			controlplane.RegisterConfigID(nodeID)
+		}
+
+		restoredNodes = append(restoredNodes, nodeID)
+	}
+
+	log.Infof("restored %d snapshots", len(restoredNodes))
+}
+
+// after all configs are loaded, restored snapshots without config must be removed.
+func reconcileSnapshots(ctx context.Context) {
+	select {
+	case <-time.After(*config.Get().EndpointCheckPeriod):
+	case <-ctx.Done():
+		return
+	}
+
+	for _, nodeID := range restoredNodes {
+		if _, ok := configstore.StoreMap.Load(nodeID); ok {
+			continue
+		}
+
+		// per node snapshots are used while envoy is connected
+		if controlplane.IsNodeHashConnected(nodeID) {
+			continue
+		}
+
+		log.Infof("node %s has no config, removing restored snapshot", nodeID)
+
+		controlplane.SnapshotCache.ClearSnapshot(nodeID)
+		controlplane.UnregisterConfigID(nodeID)
+
+		snapshotstore.DeleteAsync(ctx, nodeID)
+	}
+}
+
+func syncEnvoyNodeConfigStatus(ctx context.Context) {
+	log.Infof("syncEnvoyNodeConfigStatus every %s", *config.Get().EndpointCheckPeriod)
+
+	for ctx.Err() == nil {
+		configmapsstore.SyncEnvoyNodeConfigStatus(ctx)
+
+		select {
+		case <-time.After(*config.Get().EndpointCheckPeriod):
+		case <-ctx.Done():
+		}
+	}
 }
 
 // sync all endpoints in configs with endpointstore.
@@ -203,3 +353,23 @@ func rotateCertificates(ctx context.Context) {
 		}
 	}
 }
+
+func loadSharedCA(ctx context.Context) error {
+	keyBytes, err := certs.GetLoadedRootKeyBytes()
+	if err != nil {
+		return errors.Wrap(err, "error exporting CA key")
+	}
+
+	certBytes, keyBytes, err := api.GetSharedCA(ctx, *config.Get().Namespace, *config.Get().SSLSecret, certs.GetLoadedRootCertBytes(), keyBytes) //nolint:lll
+	if err != nil {
+		return errors.Wrap(err, "error loading shared CA")
+	}
+
+	if err := certs.SetLoadedRoot(certBytes, keyBytes); err != nil {
+		return errors.Wrap(err, "error parsing shared CA")
+	}
+
+	log.Infof("using CA from secret %s/%s", *config.Get().Namespace, *config.Get().SSLSecret)
+
+	return nil
+}
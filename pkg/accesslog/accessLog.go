@@ -0,0 +1,348 @@
+/*
+Copyright paskal.maksim@gmail.com
+Licensed under the Apache License, Version 2.0 (the "License")
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package accesslog
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"time"
+
+	alf "github.com/envoyproxy/go-control-plane/envoy/data/accesslog/v3"
+	"github.com/maksim-paskal/envoy-control-plane/pkg/config"
+	"github.com/maksim-paskal/envoy-control-plane/pkg/metrics"
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+)
+
+const (
+	SinkLog    = "log"
+	SinkStdout = "stdout"
+	SinkFile   = "file"
+	SinkOTLP   = "otlp"
+
+	BackpressureDrop  = "drop"
+	BackpressureBlock = "block"
+
+	EntryTypeHTTP = "http"
+	EntryTypeTCP  = "tcp"
+)
+
+// access log entry streamed by envoy, only one of HTTP or TCP is set.
+type Entry struct {
+	Time    time.Time
+	LogName string
+	NodeID  string
+	HTTP    *alf.HTTPAccessLogEntry
+	TCP     *alf.TCPAccessLogEntry
+}
+
+func (e *Entry) Type() string {
+	if e.HTTP != nil {
+		return EntryTypeHTTP
+	}
+
+	return EntryTypeTCP
+}
+
+func (e *Entry) GetCommonProperties() *alf.AccessLogCommon {
+	if e.HTTP != nil {
+		return e.HTTP.GetCommonProperties()
+	}
+
+	return e.TCP.GetCommonProperties()
+}
+
+func (e *Entry) message() proto.Message { //nolint:ireturn
+	if e.HTTP != nil {
+		return e.HTTP
+	}
+
+	return e.TCP
+}
+
+// access log entry that is written to sinks, entry is protojson of envoy entry.
+type Record struct {
+	Time    time.Time       `json:"time"`
+	LogName string          `json:"logName"`
+	NodeID  string          `json:"nodeId"`
+	Type    string          `json:"type"`
+	Entry   json.RawMessage `json:"entry"`
+	source  *Entry
+}
+
+// record with selected fields of entry, all fields if fields are empty.
+func NewRecord(entry *Entry, fields [][]string) (*Record, error) {
+	data, err := protojson.Marshal(entry.message())
+	if err != nil {
+		return nil, errors.Wrap(err, "error in protojson.Marshal")
+	}
+
+	if len(fields) > 0 {
+		all := make(map[string]interface{})
+
+		if err := json.Unmarshal(data, &all); err != nil {
+			return nil, errors.Wrap(err, "error in json.Unmarshal")
+		}
+
+		data, err = json.Marshal(selectFields(all, fields))
+		if err != nil {
+			return nil, errors.Wrap(err, "error in json.Marshal")
+		}
+	}
+
+	return &Record{
+		Time:    entry.Time,
+		LogName: entry.LogName,
+		NodeID:  entry.NodeID,
+		Type:    entry.Type(),
+		Entry:   data,
+		source:  entry,
+	}, nil
+}
+
+// parse comma separated json paths, for example request.authority,response.responseCode.
+func ParseFields(value string) [][]string {
+	fields := make([][]string, 0)
+
+	for _, field := range strings.Split(value, ",") {
+		if field = strings.TrimSpace(field); len(field) > 0 {
+			fields = append(fields, strings.Split(field, "."))
+		}
+	}
+
+	return fields
+}
+
+func selectFields(all map[string]interface{}, fields [][]string) map[string]interface{} {
+	result := make(map[string]interface{})
+
+	for _, path := range fields {
+		value, ok := getField(all, path)
+		if !ok {
+			continue
+		}
+
+		target := result
+
+		for _, key := range path[:len(path)-1] {
+			next, ok := target[key].(map[string]interface{})
+			if !ok {
+				next = make(map[string]interface{})
+				target[key] = next
+			}
+
+			target = next
+		}
+
+		target[path[len(path)-1]] = value
+	}
+
+	return result
+}
+
+func getField(all map[string]interface{}, path []string) (interface{}, bool) {
+	var value interface{} = all
+
+	for _, key := range path {
+		m, ok := value.(map[string]interface{})
+		if !ok {
+			return nil, false
+		}
+
+		if value, ok = m[key]; !ok {
+			return nil, false
+		}
+	}
+
+	return value, true
+}
+
+// destination of access log records.
+type Sink interface {
+	Name() string
+	Write(ctx context.Context, records []*Record) error
+	Close() error
+}
+
+type dispatcher struct {
+	ctx         context.Context //nolint:containedctx
+	entries     chan *Entry
+	sinks       []Sink
+	fields      [][]string
+	block       bool
+	batchSize   int
+	batchPeriod time.Duration
+}
+
+var active *dispatcher
+
+func Init(ctx context.Context) error {
+	d := &dispatcher{
+		ctx:         ctx,
+		entries:     make(chan *Entry, *config.Get().AccessLogBuffer),
+		fields:      ParseFields(*config.Get().AccessLogFields),
+		batchSize:   *config.Get().AccessLogBatchSize,
+		batchPeriod: *config.Get().AccessLogBatchPeriod,
+	}
+
+	switch *config.Get().AccessLogBackpressure {
+	case BackpressureDrop:
+	case BackpressureBlock:
+		d.block = true
+	default:
+		return errors.Wrap(errUnknownBackpressure, *config.Get().AccessLogBackpressure)
+	}
+
+	for _, name := range strings.Split(*config.Get().AccessLogSinks, ",") {
+		name = strings.TrimSpace(name)
+		if len(name) == 0 {
+			continue
+		}
+
+		sink, err := newSink(name)
+		if err != nil {
+			return err
+		}
+
+		d.sinks = append(d.sinks, sink)
+	}
+
+	if len(d.sinks) == 0 {
+		return nil
+	}
+
+	log.Infof("using access log sinks %s", *config.Get().AccessLogSinks)
+
+	active = d
+
+	go d.run()
+
+	return nil
+}
+
+func newSink(name string) (Sink, error) { //nolint:ireturn
+	switch name {
+	case SinkLog:
+		return &logSink{}, nil
+	case SinkStdout:
+		return newStdoutSink(), nil
+	case SinkFile:
+		return newFileSink(*config.Get().AccessLogFile, *config.Get().AccessLogFileMaxSize, *config.Get().AccessLogFileBackups)
+	case SinkOTLP:
+		return newOTLPSink(*config.Get().AccessLogOTLPEndpoint, *config.Get().AccessLogOTLPTimeout), nil
+	default:
+		return nil, errors.Wrap(errUnknownSink, name)
+	}
+}
+
+// send entry to sinks, entry is dropped or caller waits when buffer is full.
+func Push(entry *Entry) {
+	metrics.AccessLogEntries.WithLabelValues(entry.Type()).Inc()
+
+	if *config.Get().AccessLogMetrics {
+		observeEntry(entry)
+	}
+
+	tails.add(entry)
+
+	if active == nil {
+		return
+	}
+
+	active.push(entry)
+}
+
+func (d *dispatcher) push(entry *Entry) {
+	if d.block {
+		select {
+		case d.entries <- entry:
+		case <-d.ctx.Done():
+		}
+
+		return
+	}
+
+	select {
+	case d.entries <- entry:
+	default:
+		metrics.AccessLogDropped.Inc()
+	}
+}
+
+func (d *dispatcher) run() {
+	ticker := time.NewTicker(d.batchPeriod)
+	defer ticker.Stop()
+
+	batch := make([]*Entry, 0, d.batchSize)
+
+	for {
+		select {
+		case <-d.ctx.Done():
+			d.flush(batch)
+			d.close()
+
+			return
+		case entry := <-d.entries:
+			batch = append(batch, entry)
+
+			if len(batch) >= d.batchSize {
+				d.flush(batch)
+				batch = batch[:0]
+			}
+		case <-ticker.C:
+			d.flush(batch)
+			batch = batch[:0]
+		}
+	}
+}
+
+func (d *dispatcher) flush(batch []*Entry) {
+	if len(batch) == 0 {
+		return
+	}
+
+	records := make([]*Record, 0, len(batch))
+
+	for _, entry := range batch {
+		record, err := NewRecord(entry, d.fields)
+		if err != nil {
+			log.WithError(err).Warn("error creating access log record")
+
+			continue
+		}
+
+		records = append(records, record)
+	}
+
+	// sinks must finish write after shutdown
+	ctx := context.Background()
+
+	for _, sink := range d.sinks {
+		if err := sink.Write(ctx, records); err != nil {
+			metrics.AccessLogSinkErrors.WithLabelValues(sink.Name()).Inc()
+
+			log.WithError(err).Warnf("error writing access logs to %s sink", sink.Name())
+		}
+	}
+}
+
+func (d *dispatcher) close() {
+	for _, sink := range d.sinks {
+		if err := sink.Close(); err != nil {
+			log.WithError(err).Warnf("error closing %s sink", sink.Name())
+		}
+	}
+}
@@ -0,0 +1,181 @@
+/*
+Copyright paskal.maksim@gmail.com
+Licensed under the Apache License, Version 2.0 (the "License")
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package accesslog_test
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	alf "github.com/envoyproxy/go-control-plane/envoy/data/accesslog/v3"
+	"github.com/maksim-paskal/envoy-control-plane/pkg/accesslog"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+func TestNewRecord(t *testing.T) {
+	t.Parallel()
+
+	entry := &accesslog.Entry{
+		Time:    time.Now(),
+		LogName: "test",
+		NodeID:  "test1-id",
+		HTTP: &alf.HTTPAccessLogEntry{
+			CommonProperties: &alf.AccessLogCommon{
+				UpstreamCluster: "local_service",
+			},
+			Request: &alf.HTTPRequestProperties{
+				Authority: "test.local",
+				Path:      "/test",
+				RequestId: "some-id",
+			},
+			Response: &alf.HTTPResponseProperties{
+				ResponseCode: wrapperspb.UInt32(200),
+			},
+		},
+	}
+
+	record, err := accesslog.NewRecord(entry, accesslog.ParseFields("request.authority, response.responseCode,unknown.field"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if record.Type != accesslog.EntryTypeHTTP || record.NodeID != "test1-id" {
+		t.Fatalf("not correct record %+v", record)
+	}
+
+	t.Log(string(record.Entry))
+
+	selected := make(map[string]map[string]interface{})
+
+	if err := json.Unmarshal(record.Entry, &selected); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(selected) != 2 || len(selected["request"]) != 1 {
+		t.Fatalf("only selected fields must be in record, got %s", string(record.Entry))
+	}
+
+	if selected["request"]["authority"] != "test.local" || selected["response"]["responseCode"] != float64(200) {
+		t.Fatalf("not correct fields %s", string(record.Entry))
+	}
+
+	record, err = accesslog.NewRecord(entry, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	all := make(map[string]interface{})
+
+	if err := json.Unmarshal(record.Entry, &all); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, ok := all["commonProperties"]; !ok {
+		t.Fatalf("all fields must be in record, got %s", string(record.Entry))
+	}
+}
+
+func TestGetCodeClass(t *testing.T) {
+	t.Parallel()
+
+	for code, want := range map[uint32]string{
+		0:   "none",
+		200: "2xx",
+		304: "3xx",
+		404: "4xx",
+		503: "5xx",
+	} {
+		if got := accesslog.GetCodeClass(code); got != want {
+			t.Fatalf("code %d: want %s, got %s", code, want, got)
+		}
+	}
+}
+
+func TestParseCodeRange(t *testing.T) {
+	t.Parallel()
+
+	for value, want := range map[string][2]uint32{
+		"":        {0, 0},
+		"404":     {404, 404},
+		"5xx":     {500, 599},
+		"500-504": {500, 504},
+	} {
+		codeMin, codeMax, err := accesslog.ParseCodeRange(value)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if codeMin != want[0] || codeMax != want[1] {
+			t.Fatalf("%s: want %v, got %d-%d", value, want, codeMin, codeMax)
+		}
+	}
+
+	for _, value := range []string{"abc", "0xx", "504-500"} {
+		if _, _, err := accesslog.ParseCodeRange(value); err == nil {
+			t.Fatalf("%s must be invalid", value)
+		}
+	}
+}
+
+func TestTail(t *testing.T) {
+	t.Parallel()
+
+	newEntry := func(path string, code uint32) *accesslog.Entry {
+		return &accesslog.Entry{
+			Time:    time.Now(),
+			LogName: "test",
+			NodeID:  "tail-test-id",
+			HTTP: &alf.HTTPAccessLogEntry{
+				Request:  &alf.HTTPRequestProperties{Authority: "test.local", Path: path},
+				Response: &alf.HTTPResponseProperties{ResponseCode: wrapperspb.UInt32(code)},
+			},
+		}
+	}
+
+	accesslog.Push(newEntry("/api/1", 200))
+	accesslog.Push(newEntry("/api/2", 503))
+	accesslog.Push(newEntry("/static/1", 504))
+
+	filter := &accesslog.Filter{NodeID: "tail-test-id", PathPrefix: "/api", CodeMin: 500, CodeMax: 599}
+
+	if recent := accesslog.GetRecent(filter, 0); len(recent) != 1 || recent[0].HTTP.GetRequest().GetPath() != "/api/2" {
+		t.Fatalf("not correct recent entries %v", recent)
+	}
+
+	entries, cancel := accesslog.Subscribe(filter, 1)
+	defer cancel()
+
+	accesslog.Push(newEntry("/static/2", 500))
+	accesslog.Push(newEntry("/api/3", 500))
+
+	if entry := <-entries; entry.HTTP.GetRequest().GetPath() != "/api/3" {
+		t.Fatalf("not correct entry %v", entry)
+	}
+}
+
+func TestTailRingsLimit(t *testing.T) {
+	t.Parallel()
+
+	entries := []*accesslog.Entry{
+		{NodeID: "node1", LogName: "test"},
+		{NodeID: "node2", LogName: "test"},
+		{NodeID: "node1", LogName: "test"},
+		{NodeID: "node3", LogName: "test"},
+	}
+
+	// node2 is least recently updated
+	if rings := accesslog.GetTailRings(entries, 2); strings.Join(rings, ",") != "node1/test,node3/test" {
+		t.Fatalf("not correct rings %v", rings)
+	}
+}
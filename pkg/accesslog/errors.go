@@ -0,0 +1,22 @@
+/*
+Copyright paskal.maksim@gmail.com
+Licensed under the Apache License, Version 2.0 (the "License")
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package accesslog
+
+import "errors"
+
+var (
+	errUnknownSink         = errors.New("unknown access log sink")
+	errUnknownBackpressure = errors.New("unknown access log backpressure, use drop or block")
+	errOTLPStatus          = errors.New("OTLP endpoint returned error status")
+	errCodeRange           = errors.New("invalid response code range, use 500, 5xx or 500-599")
+)
@@ -0,0 +1,37 @@
+/*
+Copyright paskal.maksim@gmail.com
+Licensed under the Apache License, Version 2.0 (the "License")
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package accesslog
+
+import "sort"
+
+// keys of rings after entries are added to empty tail.
+func GetTailRings(entries []*Entry, maxRings int) []string {
+	t := &tail{
+		rings:       make(map[string]*ring),
+		subscribers: make(map[*tailSubscriber]bool),
+	}
+
+	for _, entry := range entries {
+		t.addEntry(entry, 1, maxRings)
+	}
+
+	keys := make([]string, 0, len(t.rings))
+
+	for key := range t.rings {
+		keys = append(keys, key)
+	}
+
+	sort.Strings(keys)
+
+	return keys
+}
@@ -0,0 +1,54 @@
+/*
+Copyright paskal.maksim@gmail.com
+Licensed under the Apache License, Version 2.0 (the "License")
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package accesslog
+
+import (
+	"strconv"
+
+	"github.com/maksim-paskal/envoy-control-plane/pkg/metrics"
+)
+
+const codeClassNone = "none"
+
+// response code class 2xx, 5xx, none if envoy did not send response.
+func GetCodeClass(code uint32) string {
+	if code == 0 {
+		return codeClassNone
+	}
+
+	return strconv.FormatUint(uint64(code/100), 10) + "xx" //nolint:gomnd
+}
+
+// requests count, duration and response code class of HTTP entry.
+func observeEntry(entry *Entry) {
+	if entry.HTTP == nil {
+		return
+	}
+
+	common := entry.HTTP.GetCommonProperties()
+
+	labels := []string{
+		entry.NodeID,
+		entry.LogName,
+		common.GetUpstreamCluster(),
+		common.GetRouteName(),
+	}
+
+	code := entry.HTTP.GetResponse().GetResponseCode().GetValue()
+
+	metrics.EnvoyRequests.WithLabelValues(append(labels, GetCodeClass(code))...).Inc()
+
+	if duration := common.GetTimeToLastDownstreamTxByte(); duration != nil {
+		metrics.EnvoyRequestDuration.WithLabelValues(labels...).Observe(duration.AsDuration().Seconds())
+	}
+}
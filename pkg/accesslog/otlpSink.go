@@ -0,0 +1,154 @@
+/*
+Copyright paskal.maksim@gmail.com
+Licensed under the Apache License, Version 2.0 (the "License")
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package accesslog
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/maksim-paskal/envoy-control-plane/pkg/config"
+	"github.com/pkg/errors"
+)
+
+// OTLP/HTTP logs with json encoding, https://opentelemetry.io/docs/specs/otlp/#otlphttp
+type otlpValue struct {
+	StringValue string `json:"stringValue"`
+}
+
+type otlpAttribute struct {
+	Key   string    `json:"key"`
+	Value otlpValue `json:"value"`
+}
+
+type otlpLogRecord struct {
+	TimeUnixNano         string          `json:"timeUnixNano"`
+	ObservedTimeUnixNano string          `json:"observedTimeUnixNano"`
+	SeverityText         string          `json:"severityText"`
+	Body                 otlpValue       `json:"body"`
+	Attributes           []otlpAttribute `json:"attributes"`
+}
+
+type otlpScopeLogs struct {
+	Scope struct {
+		Name    string `json:"name"`
+		Version string `json:"version"`
+	} `json:"scope"`
+	LogRecords []otlpLogRecord `json:"logRecords"`
+}
+
+type otlpResourceLogs struct {
+	Resource struct {
+		Attributes []otlpAttribute `json:"attributes"`
+	} `json:"resource"`
+	ScopeLogs []otlpScopeLogs `json:"scopeLogs"`
+}
+
+type otlpLogsRequest struct {
+	ResourceLogs []otlpResourceLogs `json:"resourceLogs"`
+}
+
+type otlpSink struct {
+	endpoint string
+	client   *http.Client
+}
+
+func newOTLPSink(endpoint string, timeout time.Duration) *otlpSink {
+	return &otlpSink{
+		endpoint: endpoint,
+		client:   &http.Client{Timeout: timeout},
+	}
+}
+
+func (s *otlpSink) Name() string {
+	return SinkOTLP
+}
+
+func newOTLPAttribute(key, value string) otlpAttribute {
+	return otlpAttribute{Key: key, Value: otlpValue{StringValue: value}}
+}
+
+func newOTLPLogsRequest(records []*Record) *otlpLogsRequest {
+	scopeLogs := otlpScopeLogs{
+		LogRecords: make([]otlpLogRecord, 0, len(records)),
+	}
+
+	scopeLogs.Scope.Name = config.AppName + "/accesslog"
+	scopeLogs.Scope.Version = config.GetVersion()
+
+	observed := strconv.FormatInt(time.Now().UnixNano(), 10) //nolint:gomnd
+
+	for _, record := range records {
+		scopeLogs.LogRecords = append(scopeLogs.LogRecords, otlpLogRecord{
+			TimeUnixNano:         strconv.FormatInt(record.Time.UnixNano(), 10), //nolint:gomnd
+			ObservedTimeUnixNano: observed,
+			SeverityText:         "INFO",
+			Body:                 otlpValue{StringValue: string(record.Entry)},
+			Attributes: []otlpAttribute{
+				newOTLPAttribute("log.name", record.LogName),
+				newOTLPAttribute("envoy.node.id", record.NodeID),
+				newOTLPAttribute("envoy.log.type", record.Type),
+			},
+		})
+	}
+
+	resourceLogs := otlpResourceLogs{
+		ScopeLogs: []otlpScopeLogs{scopeLogs},
+	}
+
+	resourceLogs.Resource.Attributes = []otlpAttribute{
+		newOTLPAttribute("service.name", config.AppName),
+	}
+
+	return &otlpLogsRequest{
+		ResourceLogs: []otlpResourceLogs{resourceLogs},
+	}
+}
+
+func (s *otlpSink) Write(ctx context.Context, records []*Record) error {
+	body, err := json.Marshal(newOTLPLogsRequest(records))
+	if err != nil {
+		return errors.Wrap(err, "error in json.Marshal")
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.endpoint, bytes.NewReader(body))
+	if err != nil {
+		return errors.Wrap(err, "error creating request")
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return errors.Wrap(err, "error sending request")
+	}
+	defer resp.Body.Close()
+
+	_, _ = io.Copy(io.Discard, resp.Body)
+
+	if resp.StatusCode < http.StatusOK || resp.StatusCode >= http.StatusMultipleChoices {
+		return errors.Wrap(errOTLPStatus, resp.Status)
+	}
+
+	return nil
+}
+
+func (s *otlpSink) Close() error {
+	s.client.CloseIdleConnections()
+
+	return nil
+}
@@ -0,0 +1,119 @@
+/*
+Copyright paskal.maksim@gmail.com
+Licensed under the Apache License, Version 2.0 (the "License")
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package accesslog
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"os"
+	"time"
+
+	"github.com/maksim-paskal/envoy-control-plane/pkg/utils"
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+)
+
+const megabyte = 1024 * 1024
+
+// short line in control-plane log.
+type logSink struct{}
+
+func (s *logSink) Name() string {
+	return SinkLog
+}
+
+func (s *logSink) Write(_ context.Context, records []*Record) error {
+	for _, record := range records {
+		entry := record.source
+		common := entry.GetCommonProperties()
+
+		if entry.HTTP != nil {
+			req := entry.HTTP.GetRequest()
+			resp := entry.HTTP.GetResponse()
+
+			log.Infof("[%s%s] %s %s %s %d %s %s",
+				record.LogName, record.Time.Format(time.RFC3339), req.GetAuthority(), req.GetPath(), req.GetScheme(),
+				resp.GetResponseCode().GetValue(), req.GetRequestId(), common.GetUpstreamCluster())
+
+			continue
+		}
+
+		log.Infof("[%s%s] tcp %s %s",
+			record.LogName, record.Time.Format(time.RFC3339), common.GetUpstreamLocalAddress(), common.GetUpstreamCluster())
+	}
+
+	return nil
+}
+
+func (s *logSink) Close() error {
+	return nil
+}
+
+// one json record per line.
+type jsonSink struct {
+	name   string
+	writer io.Writer
+	closer io.Closer
+}
+
+func newStdoutSink() *jsonSink {
+	return &jsonSink{
+		name:   SinkStdout,
+		writer: os.Stdout,
+	}
+}
+
+func newFileSink(path string, maxSize, backups int) (*jsonSink, error) {
+	file, err := utils.NewRotatingFile(path, int64(maxSize)*megabyte, backups, 0)
+	if err != nil {
+		return nil, errors.Wrap(err, "error creating file sink")
+	}
+
+	return &jsonSink{
+		name:   SinkFile,
+		writer: file,
+		closer: file,
+	}, nil
+}
+
+func (s *jsonSink) Name() string {
+	return s.name
+}
+
+func (s *jsonSink) Write(_ context.Context, records []*Record) error {
+	var buf bytes.Buffer
+
+	encoder := json.NewEncoder(&buf)
+
+	for _, record := range records {
+		if err := encoder.Encode(record); err != nil {
+			return errors.Wrap(err, "error encoding record")
+		}
+	}
+
+	if _, err := s.writer.Write(buf.Bytes()); err != nil {
+		return errors.Wrap(err, "error writing records")
+	}
+
+	return nil
+}
+
+func (s *jsonSink) Close() error {
+	if s.closer == nil {
+		return nil
+	}
+
+	return s.closer.Close()
+}
@@ -0,0 +1,260 @@
+/*
+Copyright paskal.maksim@gmail.com
+Licensed under the Apache License, Version 2.0 (the "License")
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package accesslog
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/maksim-paskal/envoy-control-plane/pkg/config"
+	"github.com/pkg/errors"
+)
+
+// filter of tail, empty fields match all entries.
+type Filter struct {
+	NodeID          string
+	LogName         string
+	Authority       string
+	PathPrefix      string
+	CodeMin         uint32
+	CodeMax         uint32
+	UpstreamCluster string
+	RequestID       string
+}
+
+func (f *Filter) hasHTTPFields() bool {
+	return len(f.Authority) > 0 || len(f.PathPrefix) > 0 || f.CodeMin > 0 || f.CodeMax > 0 || len(f.RequestID) > 0
+}
+
+func (f *Filter) Match(entry *Entry) bool {
+	if len(f.NodeID) > 0 && entry.NodeID != f.NodeID {
+		return false
+	}
+
+	if len(f.LogName) > 0 && entry.LogName != f.LogName {
+		return false
+	}
+
+	if len(f.UpstreamCluster) > 0 && entry.GetCommonProperties().GetUpstreamCluster() != f.UpstreamCluster {
+		return false
+	}
+
+	if entry.HTTP == nil {
+		return !f.hasHTTPFields()
+	}
+
+	req := entry.HTTP.GetRequest()
+
+	if len(f.Authority) > 0 && req.GetAuthority() != f.Authority {
+		return false
+	}
+
+	if len(f.PathPrefix) > 0 && !strings.HasPrefix(req.GetPath(), f.PathPrefix) {
+		return false
+	}
+
+	if len(f.RequestID) > 0 && req.GetRequestId() != f.RequestID {
+		return false
+	}
+
+	code := entry.HTTP.GetResponse().GetResponseCode().GetValue()
+
+	if f.CodeMin > 0 && code < f.CodeMin {
+		return false
+	}
+
+	if f.CodeMax > 0 && code > f.CodeMax {
+		return false
+	}
+
+	return true
+}
+
+// parse response code range 500, 5xx or 500-599.
+func ParseCodeRange(value string) (uint32, uint32, error) {
+	if len(value) == 0 {
+		return 0, 0, nil
+	}
+
+	if class := strings.TrimSuffix(strings.ToLower(value), "xx"); len(class) == 1 && class != value {
+		digit, err := strconv.ParseUint(class, 10, 32)
+		if err != nil || digit == 0 {
+			return 0, 0, errors.Wrap(errCodeRange, value)
+		}
+
+		return uint32(digit) * 100, uint32(digit)*100 + 99, nil //nolint:gomnd,gosec
+	}
+
+	minMax := strings.SplitN(value, "-", 2) //nolint:gomnd
+
+	codeMin, err := strconv.ParseUint(strings.TrimSpace(minMax[0]), 10, 32)
+	if err != nil {
+		return 0, 0, errors.Wrap(errCodeRange, value)
+	}
+
+	codeMax := codeMin
+
+	if len(minMax) > 1 {
+		if codeMax, err = strconv.ParseUint(strings.TrimSpace(minMax[1]), 10, 32); err != nil || codeMax < codeMin {
+			return 0, 0, errors.Wrap(errCodeRange, value)
+		}
+	}
+
+	return uint32(codeMin), uint32(codeMax), nil //nolint:gosec
+}
+
+// fixed size buffer of recent entries, oldest entry is overwritten.
+type ring struct {
+	entries []*Entry
+	next    int
+	// sequence of last update
+	updated uint64
+}
+
+func (r *ring) add(entry *Entry) {
+	if len(r.entries) < cap(r.entries) {
+		r.entries = append(r.entries, entry)
+
+		return
+	}
+
+	r.entries[r.next] = entry
+	r.next = (r.next + 1) % len(r.entries)
+}
+
+type tailSubscriber struct {
+	filter  *Filter
+	entries chan *Entry
+}
+
+type tail struct {
+	mutex sync.RWMutex
+	// recent entries by node id and log name
+	rings       map[string]*ring
+	subscribers map[*tailSubscriber]bool
+	sequence    uint64
+}
+
+var tails = &tail{
+	rings:       make(map[string]*ring),
+	subscribers: make(map[*tailSubscriber]bool),
+}
+
+func (t *tail) add(entry *Entry) {
+	t.addEntry(entry, *config.Get().AccessLogTailSize, *config.Get().AccessLogTailRings)
+}
+
+func (t *tail) addEntry(entry *Entry, size, maxRings int) {
+	if size <= 0 {
+		return
+	}
+
+	key := entry.NodeID + "/" + entry.LogName
+
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	r, ok := t.rings[key]
+	if !ok {
+		t.evict(maxRings - 1)
+
+		r = &ring{entries: make([]*Entry, 0, size)}
+		t.rings[key] = r
+	}
+
+	t.sequence++
+
+	r.updated = t.sequence
+	r.add(entry)
+
+	for subscriber := range t.subscribers {
+		if !subscriber.filter.Match(entry) {
+			continue
+		}
+
+		// slow subscriber must not block envoy stream
+		select {
+		case subscriber.entries <- entry:
+		default:
+		}
+	}
+}
+
+// remove least recently updated rings until count of rings is not more than max,
+// rings of disconnected nodes are not updated.
+func (t *tail) evict(maxRings int) {
+	if maxRings < 0 {
+		maxRings = 0
+	}
+
+	for len(t.rings) > maxRings {
+		oldestKey := ""
+		oldest := uint64(0)
+
+		for key, r := range t.rings {
+			if len(oldestKey) == 0 || r.updated < oldest {
+				oldestKey = key
+				oldest = r.updated
+			}
+		}
+
+		delete(t.rings, oldestKey)
+	}
+}
+
+// recent entries that match filter sorted by time, limit 0 returns all entries.
+func GetRecent(filter *Filter, limit int) []*Entry {
+	tails.mutex.RLock()
+
+	result := make([]*Entry, 0)
+
+	for _, r := range tails.rings {
+		for _, entry := range r.entries {
+			if filter.Match(entry) {
+				result = append(result, entry)
+			}
+		}
+	}
+
+	tails.mutex.RUnlock()
+
+	sort.SliceStable(result, func(i, j int) bool {
+		return result[i].Time.Before(result[j].Time)
+	})
+
+	if limit > 0 && len(result) > limit {
+		result = result[len(result)-limit:]
+	}
+
+	return result
+}
+
+// new entries that match filter, cancel must be called when subscriber is done.
+func Subscribe(filter *Filter, buffer int) (<-chan *Entry, func()) {
+	subscriber := &tailSubscriber{
+		filter:  filter,
+		entries: make(chan *Entry, buffer),
+	}
+
+	tails.mutex.Lock()
+	tails.subscribers[subscriber] = true
+	tails.mutex.Unlock()
+
+	return subscriber.entries, func() {
+		tails.mutex.Lock()
+		delete(tails.subscribers, subscriber)
+		tails.mutex.Unlock()
+	}
+}
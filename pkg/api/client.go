@@ -16,20 +16,27 @@ import (
 	"github.com/maksim-paskal/envoy-control-plane/pkg/config"
 	"github.com/pkg/errors"
 	log "github.com/sirupsen/logrus"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/dynamic/dynamicinformer"
 	"k8s.io/client-go/informers"
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/rest"
 	"k8s.io/client-go/tools/clientcmd"
 	k8sMetrics "k8s.io/client-go/tools/metrics"
+	"k8s.io/client-go/tools/record"
 )
 
 const defaultResync = 0
 
 type client struct {
-	stopCh     chan struct{}
-	clientset  *kubernetes.Clientset
-	restconfig *rest.Config
-	factory    informers.SharedInformerFactory
+	stopCh         chan struct{}
+	clientset      *kubernetes.Clientset
+	dynamicClient  dynamic.Interface
+	restconfig     *rest.Config
+	factory        informers.SharedInformerFactory
+	dynamicFactory dynamicinformer.DynamicSharedInformerFactory
+	eventRecorder  record.EventRecorder
 }
 
 var Client *client
@@ -42,6 +49,10 @@ func Init() error {
 		return errors.Wrap(err, "error creating newClient")
 	}
 
+	if err := newRemoteClusters(); err != nil {
+		return errors.Wrap(err, "error creating remote clusters")
+	}
+
 	Client.RunAndWait()
 
 	return nil
@@ -78,25 +89,42 @@ func newClient() (*client, error) {
 		log.WithError(err).Fatal()
 	}
 
+	client.eventRecorder = client.newEventRecorder()
+
+	client.dynamicClient, err = dynamic.NewForConfig(client.restconfig)
+	if err != nil {
+		log.WithError(err).Fatal()
+	}
+
+	client.dynamicFactory = dynamicinformer.NewFilteredDynamicSharedInformerFactory(
+		client.dynamicClient,
+		defaultResync,
+		getWatchNamespace(),
+		nil,
+	)
+
 	if *config.Get().WatchNamespaced {
 		log.Infof("start namespaced, namespace=%s", *config.Get().Namespace)
-
-		client.factory = informers.NewSharedInformerFactoryWithOptions(
-			client.clientset,
-			defaultResync,
-			informers.WithNamespace(*config.Get().Namespace),
-		)
-	} else {
-		client.factory = informers.NewSharedInformerFactoryWithOptions(
-			client.clientset,
-			defaultResync,
-			informers.WithNamespace(*config.Get().Namespace),
-		)
 	}
 
+	client.factory = informers.NewSharedInformerFactoryWithOptions(
+		client.clientset,
+		defaultResync,
+		informers.WithNamespace(getWatchNamespace()),
+	)
+
 	return &client, nil
 }
 
+// namespace of informers, all namespaces if not namespaced.
+func getWatchNamespace() string {
+	if *config.Get().WatchNamespaced {
+		return *config.Get().Namespace
+	}
+
+	return metav1.NamespaceAll
+}
+
 func (c *client) KubeFactory() informers.SharedInformerFactory { //nolint:ireturn
 	return c.factory
 }
@@ -105,6 +133,10 @@ func (c *client) KubeClient() *kubernetes.Clientset {
 	return c.clientset
 }
 
+func (c *client) DynamicClient() dynamic.Interface { //nolint:ireturn
+	return c.dynamicClient
+}
+
 func (c *client) RunAndWait() {
 	c.factory.Start(c.stopCh)
 	c.factory.WaitForCacheSync(c.stopCh)
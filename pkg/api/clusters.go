@@ -0,0 +1,187 @@
+/*
+Copyright paskal.maksim@gmail.com
+Licensed under the Apache License, Version 2.0 (the "License")
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package api
+
+import (
+	"sort"
+
+	"github.com/maksim-paskal/envoy-control-plane/pkg/config"
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+	v1 "k8s.io/api/core/v1"
+	discoveryv1 "k8s.io/api/discovery/v1"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes"
+	listerv1 "k8s.io/client-go/listers/core/v1"
+	discoverylisterv1 "k8s.io/client-go/listers/discovery/v1"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/tools/clientcmd"
+)
+
+// informers of kubernetes cluster that used for endpoints discovery.
+type kubeCluster struct {
+	name                  string
+	factory               informers.SharedInformerFactory
+	podInformer           cache.SharedIndexInformer
+	podLister             listerv1.PodLister
+	nodeInformer          cache.SharedIndexInformer
+	nodeLister            listerv1.NodeLister
+	endpointSliceInformer cache.SharedIndexInformer
+	endpointSliceLister   discoverylisterv1.EndpointSliceLister
+}
+
+// remote clusters by name, created on Init and not changed after.
+var remoteClusters = make(map[string]*kubeCluster)
+
+func newRemoteClusters() error {
+	remoteKubeConfigs, err := config.ParseRemoteKubeConfigs(*config.Get().KubeConfigRemote)
+	if err != nil {
+		return err
+	}
+
+	for name, path := range remoteKubeConfigs {
+		restconfig, err := clientcmd.BuildConfigFromFlags("", path)
+		if err != nil {
+			return errors.Wrapf(err, "error loading kubeconfig of cluster %s", name)
+		}
+
+		clientset, err := kubernetes.NewForConfig(restconfig)
+		if err != nil {
+			return errors.Wrapf(err, "error creating clientset of cluster %s", name)
+		}
+
+		factory := informers.NewSharedInformerFactoryWithOptions(
+			clientset,
+			defaultResync,
+			informers.WithNamespace(getWatchNamespace()),
+		)
+
+		remoteClusters[name] = &kubeCluster{
+			name:                  name,
+			factory:               factory,
+			podInformer:           factory.Core().V1().Pods().Informer(),
+			podLister:             factory.Core().V1().Pods().Lister(),
+			nodeInformer:          factory.Core().V1().Nodes().Informer(),
+			nodeLister:            factory.Core().V1().Nodes().Lister(),
+			endpointSliceInformer: factory.Discovery().V1().EndpointSlices().Informer(),
+			endpointSliceLister:   factory.Discovery().V1().EndpointSlices().Lister(),
+		}
+
+		log.Infof("remote cluster %s loaded from %s", name, path)
+	}
+
+	return nil
+}
+
+// remote cluster is not required for control plane to work,
+// informers are started without waiting for cache sync.
+func (c *client) runRemoteInformers() {
+	for _, remoteCluster := range remoteClusters {
+		_, _ = remoteCluster.podInformer.AddEventHandler(podEventHandler())
+		_, _ = remoteCluster.endpointSliceInformer.AddEventHandler(endpointSliceEventHandler())
+
+		for _, informer := range []cache.SharedIndexInformer{
+			remoteCluster.podInformer,
+			remoteCluster.nodeInformer,
+			remoteCluster.endpointSliceInformer,
+		} {
+			if err := informer.SetWatchErrorHandler(remoteCluster.watchErrors); err != nil {
+				log.WithError(err).Fatal()
+			}
+		}
+
+		remoteCluster.factory.Start(c.stopCh)
+
+		go func(remoteCluster *kubeCluster) {
+			for informerType, synced := range remoteCluster.factory.WaitForCacheSync(c.stopCh) {
+				if !synced {
+					log.WithError(errTimeout).Errorf("cluster=%s,informer=%s", remoteCluster.name, informerType)
+				}
+			}
+
+			log.Infof("remote cluster %s informers synced", remoteCluster.name)
+		}(remoteCluster)
+	}
+}
+
+func (k *kubeCluster) watchErrors(_ *cache.Reflector, err error) {
+	log.WithError(err).WithField("cluster", k.name).Error("remote cluster watch error")
+}
+
+// return informers of cluster by name, current cluster has name from -cluster.name.
+func getKubeCluster(name string) (*kubeCluster, error) {
+	if name == *config.Get().ClusterName {
+		return &kubeCluster{
+			name:                name,
+			podLister:           podLister,
+			nodeLister:          nodeLister,
+			endpointSliceLister: endpointSliceLister,
+		}, nil
+	}
+
+	if remoteCluster, ok := remoteClusters[name]; ok {
+		return remoteCluster, nil
+	}
+
+	return nil, errors.Wrap(errUnknownCluster, name)
+}
+
+// names of all kubernetes clusters.
+func GetClusterNames() []string {
+	result := []string{*config.Get().ClusterName}
+
+	for name := range remoteClusters {
+		result = append(result, name)
+	}
+
+	sort.Strings(result[1:])
+
+	return result
+}
+
+func GetClusterPod(cluster, namespace, name string) (*v1.Pod, error) {
+	kubeCluster, err := getKubeCluster(cluster)
+	if err != nil {
+		return nil, err
+	}
+
+	return kubeCluster.podLister.Pods(namespace).Get(name)
+}
+
+// pods of kubernetes entry namespace, all watched namespaces if namespace is empty.
+func ListClusterPods(cluster, namespace string, selectorSet map[string]string) ([]*v1.Pod, error) {
+	kubeCluster, err := getKubeCluster(cluster)
+	if err != nil {
+		return nil, err
+	}
+
+	return listPods(kubeCluster.podLister, namespace, selectorSet)
+}
+
+func GetClusterNode(cluster, name string) (*v1.Node, error) {
+	kubeCluster, err := getKubeCluster(cluster)
+	if err != nil {
+		return nil, err
+	}
+
+	return kubeCluster.nodeLister.Get(name)
+}
+
+func GetClusterEndpointSlices(cluster, namespace, name string) ([]*discoveryv1.EndpointSlice, error) {
+	kubeCluster, err := getKubeCluster(cluster)
+	if err != nil {
+		return nil, err
+	}
+
+	return getEndpointSlices(kubeCluster.endpointSliceLister, namespace, name)
+}
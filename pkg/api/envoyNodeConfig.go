@@ -0,0 +1,198 @@
+/*
+Copyright paskal.maksim@gmail.com
+Licensed under the Apache License, Version 2.0 (the "License")
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package api
+
+import (
+	"context"
+	"reflect"
+
+	"github.com/maksim-paskal/envoy-control-plane/pkg/metrics"
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/tools/cache"
+)
+
+var EnvoyNodeConfigResource = schema.GroupVersionResource{
+	Group:    "envoy-control-plane.paskal-dev.com",
+	Version:  "v1alpha1",
+	Resource: "envoynodeconfigs",
+}
+
+// EnvoyNodeConfig reconciliation results.
+type EnvoyNodeConfigStatus struct {
+	ObservedGeneration int64    `json:"observedGeneration"`
+	LastPushedVersion  string   `json:"lastPushedVersion,omitempty"`
+	ParseErrors        []string `json:"parseErrors,omitempty"`
+	ConnectedNodes     int64    `json:"connectedNodes"`
+}
+
+var (
+	envoyNodeConfigInformer cache.SharedIndexInformer
+
+	OnNewEnvoyNodeConfig    func(*unstructured.Unstructured)
+	OnDeleteEnvoyNodeConfig func(*unstructured.Unstructured)
+)
+
+func (c *client) runEnvoyNodeConfigInformer() {
+	envoyNodeConfigInformer = c.dynamicFactory.ForResource(EnvoyNodeConfigResource).Informer()
+
+	_, _ = envoyNodeConfigInformer.AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc: func(obj interface{}) {
+			metrics.ConfigmapsstoreAddFunc.Inc()
+
+			log.Debug("envoyNodeConfigInformer.AddFunc")
+			enc, ok := obj.(*unstructured.Unstructured)
+			if !ok {
+				log.WithError(errAssertion).Fatal("obj.(*unstructured.Unstructured)")
+			}
+
+			if OnNewEnvoyNodeConfig != nil {
+				OnNewEnvoyNodeConfig(enc)
+			}
+		},
+		UpdateFunc: func(old, cur interface{}) {
+			metrics.ConfigmapsstoreUpdateFunc.Inc()
+
+			log.Debug("envoyNodeConfigInformer.UpdateFunc")
+			curConfig, ok := cur.(*unstructured.Unstructured)
+			if !ok {
+				log.WithError(errAssertion).Fatal("cur.(*unstructured.Unstructured)")
+			}
+
+			oldConfig, ok := old.(*unstructured.Unstructured)
+			if !ok {
+				log.WithError(errAssertion).Fatal("old.(*unstructured.Unstructured)")
+			}
+
+			// status updates do not change generation, labels are used for version label
+			if curConfig.GetGeneration() == oldConfig.GetGeneration() &&
+				reflect.DeepEqual(curConfig.GetAnnotations(), oldConfig.GetAnnotations()) &&
+				reflect.DeepEqual(curConfig.GetLabels(), oldConfig.GetLabels()) {
+				return
+			}
+
+			if OnNewEnvoyNodeConfig != nil {
+				OnNewEnvoyNodeConfig(curConfig)
+			}
+		},
+		DeleteFunc: func(obj interface{}) {
+			metrics.ConfigmapsstoreDeleteFunc.Inc()
+
+			log.Debug("envoyNodeConfigInformer.DeleteFunc")
+
+			if tombstone, ok := obj.(cache.DeletedFinalStateUnknown); ok {
+				obj = tombstone.Obj
+			}
+
+			enc, ok := obj.(*unstructured.Unstructured)
+			if !ok {
+				log.WithError(errAssertion).Fatal("obj.(*unstructured.Unstructured)")
+			}
+
+			if OnDeleteEnvoyNodeConfig != nil {
+				OnDeleteEnvoyNodeConfig(enc)
+			}
+		},
+	})
+
+	err := envoyNodeConfigInformer.SetWatchErrorHandler(watchErrors)
+	if err != nil {
+		log.WithError(err).Fatal()
+	}
+
+	go envoyNodeConfigInformer.Run(c.stopCh)
+
+	if !cache.WaitForCacheSync(c.stopCh, envoyNodeConfigInformer.HasSynced) {
+		log.WithError(errTimeout).Fatal()
+	}
+}
+
+func GetEnvoyNodeConfigStatus(enc *unstructured.Unstructured) (*EnvoyNodeConfigStatus, error) {
+	status := EnvoyNodeConfigStatus{}
+
+	statusObj, ok, err := unstructured.NestedMap(enc.Object, "status")
+	if err != nil {
+		return nil, errors.Wrap(err, "error getting status")
+	}
+
+	if !ok {
+		return &status, nil
+	}
+
+	if err := runtime.DefaultUnstructuredConverter.FromUnstructured(statusObj, &status); err != nil {
+		return nil, errors.Wrap(err, "error converting status")
+	}
+
+	return &status, nil
+}
+
+// write status only if it was changed.
+func UpdateEnvoyNodeConfigStatus(ctx context.Context, namespace, name string, status *EnvoyNodeConfigStatus) error {
+	if !IsLeader() {
+		return nil
+	}
+
+	enc, err := Client.DynamicClient().Resource(EnvoyNodeConfigResource).Namespace(namespace).Get(ctx, name, metav1.GetOptions{}) //nolint:lll
+	if err != nil {
+		return errors.Wrap(err, "error getting EnvoyNodeConfig")
+	}
+
+	currentStatus, err := GetEnvoyNodeConfigStatus(enc)
+	if err != nil {
+		return err
+	}
+
+	if reflect.DeepEqual(currentStatus, status) {
+		return nil
+	}
+
+	statusObj, err := runtime.DefaultUnstructuredConverter.ToUnstructured(status)
+	if err != nil {
+		return errors.Wrap(err, "error converting status")
+	}
+
+	if err := unstructured.SetNestedMap(enc.Object, statusObj, "status"); err != nil {
+		return errors.Wrap(err, "error setting status")
+	}
+
+	_, err = Client.DynamicClient().Resource(EnvoyNodeConfigResource).Namespace(namespace).UpdateStatus(ctx, enc, metav1.UpdateOptions{}) //nolint:lll
+	if err != nil {
+		return errors.Wrap(err, "error updating status")
+	}
+
+	return nil
+}
+
+func ListEnvoyNodeConfigs() ([]*unstructured.Unstructured, error) {
+	if envoyNodeConfigInformer == nil {
+		return nil, nil
+	}
+
+	items := envoyNodeConfigInformer.GetStore().List()
+	result := make([]*unstructured.Unstructured, 0, len(items))
+
+	for _, item := range items {
+		enc, ok := item.(*unstructured.Unstructured)
+		if !ok {
+			return nil, errAssertion
+		}
+
+		result = append(result, enc)
+	}
+
+	return result, nil
+}
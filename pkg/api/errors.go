@@ -15,6 +15,8 @@ package api
 import "errors"
 
 var (
-	errTimeout   = errors.New("timed out waiting for caches to sync")
-	errAssertion = errors.New("assertion error")
+	errTimeout         = errors.New("timed out waiting for caches to sync")
+	errAssertion       = errors.New("assertion error")
+	errUnknownCluster  = errors.New("unknown kubernetes cluster")
+	errInvalidCASecret = errors.New("secret has no tls.crt or tls.key")
 )
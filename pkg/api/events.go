@@ -0,0 +1,100 @@
+/*
+Copyright paskal.maksim@gmail.com
+Licensed under the Apache License, Version 2.0 (the "License")
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package api
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/maksim-paskal/envoy-control-plane/pkg/config"
+	"github.com/pkg/errors"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/scheme"
+	typedcorev1 "k8s.io/client-go/kubernetes/typed/core/v1"
+	"k8s.io/client-go/tools/record"
+)
+
+const (
+	eventComponent = "envoy-control-plane"
+
+	EventReasonConfigApplied     = "ConfigApplied"
+	EventReasonConfigRejected    = "ConfigRejected"
+	EventReasonConfigRolledBack  = "ConfigRolledBack"
+	EventReasonInvalidEndpointIP = "InvalidEndpointIP"
+
+	AnnotationLastAppliedVersion = "envoy-control-plane/last-applied-version"
+	AnnotationLastAppliedTime    = "envoy-control-plane/last-applied-time"
+)
+
+func (c *client) newEventRecorder() record.EventRecorder { //nolint:ireturn
+	broadcaster := record.NewBroadcaster()
+	broadcaster.StartRecordingToSink(&typedcorev1.EventSinkImpl{
+		Interface: c.clientset.CoreV1().Events(""),
+	})
+
+	return broadcaster.NewRecorder(scheme.Scheme, v1.EventSource{Component: eventComponent})
+}
+
+// create kubernetes event on object.
+func Event(object runtime.Object, eventType, reason, messageFmt string, args ...interface{}) {
+	if Client == nil || Client.eventRecorder == nil || !IsLeader() {
+		return
+	}
+
+	Client.eventRecorder.Eventf(object, eventType, reason, messageFmt, args...)
+}
+
+// reference to ConfigMap or EnvoyNodeConfig that was source of config.
+func ConfigSourceReference(kind, namespace, name string) *v1.ObjectReference {
+	ref := v1.ObjectReference{
+		Kind:      kind,
+		Namespace: namespace,
+		Name:      name,
+	}
+
+	switch kind {
+	case config.ConfigSourceEnvoyNodeConfig:
+		ref.APIVersion = EnvoyNodeConfigResource.GroupVersion().String()
+	default:
+		ref.Kind = config.ConfigSourceConfigMap
+		ref.APIVersion = "v1"
+	}
+
+	return &ref
+}
+
+// set ConfigMap annotations with merge patch, other annotations will not be changed.
+func PatchConfigMapAnnotations(ctx context.Context, namespace, name string, annotations map[string]string) error {
+	if !IsLeader() {
+		return nil
+	}
+
+	patch, err := json.Marshal(map[string]interface{}{
+		"metadata": map[string]interface{}{
+			"annotations": annotations,
+		},
+	})
+	if err != nil {
+		return errors.Wrap(err, "json.Marshal")
+	}
+
+	_, err = Client.KubeClient().CoreV1().ConfigMaps(namespace).Patch(ctx, name, types.MergePatchType, patch, metav1.PatchOptions{}) //nolint:lll
+	if err != nil {
+		return errors.Wrap(err, "error patching ConfigMap")
+	}
+
+	return nil
+}
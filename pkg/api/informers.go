@@ -22,10 +22,12 @@ import (
 	"github.com/maksim-paskal/envoy-control-plane/pkg/metrics"
 	log "github.com/sirupsen/logrus"
 	v1 "k8s.io/api/core/v1"
+	discoveryv1 "k8s.io/api/discovery/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/util/runtime"
 	listerv1 "k8s.io/client-go/listers/core/v1"
+	discoverylisterv1 "k8s.io/client-go/listers/discovery/v1"
 	"k8s.io/client-go/tools/cache"
 )
 
@@ -35,20 +37,20 @@ const (
 )
 
 var (
-	podInformer       cache.SharedIndexInformer
-	podLister         listerv1.PodLister
-	nodeInformer      cache.SharedIndexInformer
-	nodeLister        listerv1.NodeLister
-	configInformer    cache.SharedIndexInformer
-	configLister      listerv1.ConfigMapLister
-	endpointsInformer cache.SharedIndexInformer
-	endpointsLister   listerv1.EndpointsLister
+	podInformer           cache.SharedIndexInformer
+	podLister             listerv1.PodLister
+	nodeInformer          cache.SharedIndexInformer
+	nodeLister            listerv1.NodeLister
+	configInformer        cache.SharedIndexInformer
+	configLister          listerv1.ConfigMapLister
+	endpointSliceInformer cache.SharedIndexInformer
+	endpointSliceLister   discoverylisterv1.EndpointSliceLister
 
 	OnNewPod       func(pod *v1.Pod)
 	OnDeletePod    func(pod *v1.Pod)
 	OnNewConfig    func(*v1.ConfigMap)
 	OnDeleteConfig func(*v1.ConfigMap)
-	OnNewEndpoints func(pod *v1.Endpoints)
+	OnNewEndpoints func(endpointSlice *discoveryv1.EndpointSlice)
 )
 
 func (c *client) RunKubeInformers(ctx context.Context) {
@@ -63,50 +65,10 @@ func (c *client) RunKubeInformers(ctx context.Context) {
 	configInformer = Client.KubeFactory().Core().V1().ConfigMaps().Informer()
 	configLister = Client.KubeFactory().Core().V1().ConfigMaps().Lister()
 
-	endpointsInformer = Client.KubeFactory().Core().V1().Endpoints().Informer()
-	endpointsLister = Client.KubeFactory().Core().V1().Endpoints().Lister()
+	endpointSliceInformer = Client.KubeFactory().Discovery().V1().EndpointSlices().Informer()
+	endpointSliceLister = Client.KubeFactory().Discovery().V1().EndpointSlices().Lister()
 
-	_, _ = podInformer.AddEventHandler(cache.ResourceEventHandlerFuncs{
-		AddFunc: func(obj interface{}) {
-			metrics.EndpointstoreAddFunc.Inc()
-
-			log.Debug("podInformer.AddFunc")
-			pod, ok := obj.(*v1.Pod)
-			if !ok {
-				log.WithError(errAssertion).Fatal("obj.(*v1.Pod)")
-			}
-
-			if OnNewPod != nil {
-				OnNewPod(pod)
-			}
-		},
-		UpdateFunc: func(_ interface{}, newObj interface{}) {
-			metrics.EndpointstoreUpdateFunc.Inc()
-
-			log.Debug("podInformer.UpdateFunc")
-			pod, ok := newObj.(*v1.Pod)
-			if !ok {
-				log.WithError(errAssertion).Fatal("obj.(*v1.Pod)")
-			}
-
-			if OnNewPod != nil {
-				OnNewPod(pod)
-			}
-		},
-		DeleteFunc: func(obj interface{}) {
-			metrics.EndpointstoreDeleteFunc.Inc()
-
-			log.Debug("podInformer.DeleteFunc")
-			pod, ok := obj.(*v1.Pod)
-			if !ok {
-				log.WithError(errAssertion).Fatal("obj.(*v1.Pod)")
-			}
-
-			if OnDeletePod != nil {
-				OnDeletePod(pod)
-			}
-		},
-	})
+	_, _ = podInformer.AddEventHandler(podEventHandler())
 
 	_, _ = configInformer.AddEventHandler(cache.ResourceEventHandlerFuncs{
 		AddFunc: func(obj interface{}) {
@@ -165,30 +127,7 @@ func (c *client) RunKubeInformers(ctx context.Context) {
 		},
 	})
 
-	_, _ = endpointsInformer.AddEventHandler(cache.ResourceEventHandlerFuncs{
-		AddFunc: func(obj interface{}) {
-			log.Debug("endpointsInformer.AddFunc")
-			endpoints, ok := obj.(*v1.Endpoints)
-			if !ok {
-				log.WithError(errAssertion).Fatal("obj.(*v1.Endpoints)")
-			}
-
-			if OnNewEndpoints != nil {
-				OnNewEndpoints(endpoints)
-			}
-		},
-		UpdateFunc: func(_, cur interface{}) {
-			log.Debug("endpointsInformer.UpdateFunc")
-			endpoints, ok := cur.(*v1.Endpoints)
-			if !ok {
-				log.WithError(errAssertion).Fatal("cur.(*v1.Endpoints)")
-			}
-
-			if OnNewEndpoints != nil {
-				OnNewEndpoints(endpoints)
-			}
-		},
-	})
+	_, _ = endpointSliceInformer.AddEventHandler(endpointSliceEventHandler())
 
 	err := podInformer.SetWatchErrorHandler(watchErrors)
 	if err != nil {
@@ -205,7 +144,7 @@ func (c *client) RunKubeInformers(ctx context.Context) {
 		log.WithError(err).Fatal()
 	}
 
-	err = endpointsInformer.SetWatchErrorHandler(watchErrors)
+	err = endpointSliceInformer.SetWatchErrorHandler(watchErrors)
 	if err != nil {
 		log.WithError(err).Fatal()
 	}
@@ -217,7 +156,7 @@ func (c *client) RunKubeInformers(ctx context.Context) {
 
 	go podInformer.Run(c.stopCh)
 	go configInformer.Run(c.stopCh)
-	go endpointsInformer.Run(c.stopCh)
+	go endpointSliceInformer.Run(c.stopCh)
 
 	if !cache.WaitForCacheSync(c.stopCh, podInformer.HasSynced) {
 		log.WithError(errTimeout).Fatal()
@@ -231,10 +170,16 @@ func (c *client) RunKubeInformers(ctx context.Context) {
 		log.WithError(errTimeout).Fatal()
 	}
 
-	if !cache.WaitForCacheSync(c.stopCh, endpointsInformer.HasSynced) {
+	if !cache.WaitForCacheSync(c.stopCh, endpointSliceInformer.HasSynced) {
 		log.WithError(errTimeout).Fatal()
 	}
 
+	if *config.Get().EnvoyNodeConfig {
+		c.runEnvoyNodeConfigInformer()
+	}
+
+	c.runRemoteInformers()
+
 	go func() {
 		<-ctx.Done()
 
@@ -242,6 +187,97 @@ func (c *client) RunKubeInformers(ctx context.Context) {
 	}()
 }
 
+// pods events of all kubernetes clusters.
+func podEventHandler() cache.ResourceEventHandlerFuncs {
+	return cache.ResourceEventHandlerFuncs{
+		AddFunc: func(obj interface{}) {
+			metrics.EndpointstoreAddFunc.Inc()
+
+			log.Debug("podInformer.AddFunc")
+			pod, ok := obj.(*v1.Pod)
+			if !ok {
+				log.WithError(errAssertion).Fatal("obj.(*v1.Pod)")
+			}
+
+			if OnNewPod != nil {
+				OnNewPod(pod)
+			}
+		},
+		UpdateFunc: func(_ interface{}, newObj interface{}) {
+			metrics.EndpointstoreUpdateFunc.Inc()
+
+			log.Debug("podInformer.UpdateFunc")
+			pod, ok := newObj.(*v1.Pod)
+			if !ok {
+				log.WithError(errAssertion).Fatal("obj.(*v1.Pod)")
+			}
+
+			if OnNewPod != nil {
+				OnNewPod(pod)
+			}
+		},
+		DeleteFunc: func(obj interface{}) {
+			metrics.EndpointstoreDeleteFunc.Inc()
+
+			log.Debug("podInformer.DeleteFunc")
+			pod, ok := obj.(*v1.Pod)
+			if !ok {
+				log.WithError(errAssertion).Fatal("obj.(*v1.Pod)")
+			}
+
+			if OnDeletePod != nil {
+				OnDeletePod(pod)
+			}
+		},
+	}
+}
+
+// EndpointSlices events of all kubernetes clusters.
+func endpointSliceEventHandler() cache.ResourceEventHandlerFuncs {
+	return cache.ResourceEventHandlerFuncs{
+		AddFunc: func(obj interface{}) {
+			log.Debug("endpointSliceInformer.AddFunc")
+			endpointSlice, ok := obj.(*discoveryv1.EndpointSlice)
+			if !ok {
+				log.WithError(errAssertion).Fatal("obj.(*discoveryv1.EndpointSlice)")
+			}
+
+			if OnNewEndpoints != nil {
+				OnNewEndpoints(endpointSlice)
+			}
+		},
+		UpdateFunc: func(_, cur interface{}) {
+			log.Debug("endpointSliceInformer.UpdateFunc")
+			endpointSlice, ok := cur.(*discoveryv1.EndpointSlice)
+			if !ok {
+				log.WithError(errAssertion).Fatal("cur.(*discoveryv1.EndpointSlice)")
+			}
+
+			if OnNewEndpoints != nil {
+				OnNewEndpoints(endpointSlice)
+			}
+		},
+		DeleteFunc: func(obj interface{}) {
+			log.Debug("endpointSliceInformer.DeleteFunc")
+
+			// slices of one service are deleted when service scales down,
+			// the remaining slices must be published again
+			if tombstone, ok := obj.(cache.DeletedFinalStateUnknown); ok {
+				obj = tombstone.Obj
+			}
+
+			endpointSlice, ok := obj.(*discoveryv1.EndpointSlice)
+			if !ok {
+				log.WithError(errAssertion).Fatal("obj.(*discoveryv1.EndpointSlice)")
+			}
+
+			if OnNewEndpoints != nil {
+				OnNewEndpoints(endpointSlice)
+			}
+		},
+	}
+}
+
 func watchErrors(_ *cache.Reflector, err error) {
 	log.WithError(err).Fatal()
 }
@@ -251,9 +287,13 @@ func GetPod(namespace, name string) (*v1.Pod, error) {
 }
 
 func ListPods(selectorSet map[string]string) ([]*v1.Pod, error) {
+	return listPods(podLister, v1.NamespaceAll, selectorSet)
+}
+
+func listPods(lister listerv1.PodLister, namespace string, selectorSet map[string]string) ([]*v1.Pod, error) {
 	selector := labels.Set(selectorSet).AsSelector()
 
-	return podLister.List(selector)
+	return lister.Pods(namespace).List(selector)
 }
 
 func ListConfigMaps() ([]*v1.ConfigMap, error) {
@@ -264,28 +304,50 @@ func GetNode(name string) (*v1.Node, error) {
 	return nodeLister.Get(name)
 }
 
-func GetEndpoint(name string) (*v1.Endpoints, error) {
-	endpoints, err := endpointsLister.List(labels.Everything())
+// return all EndpointSlices of service, nil if service has no slices.
+func GetEndpointSlices(name string) ([]*discoveryv1.EndpointSlice, error) {
+	return getEndpointSlices(endpointSliceLister, v1.NamespaceAll, name)
+}
+
+func getEndpointSlices(lister discoverylisterv1.EndpointSliceLister, namespace, name string) ([]*discoveryv1.EndpointSlice, error) { //nolint:lll
+	selector := labels.Set{discoveryv1.LabelServiceName: name}.AsSelector()
+
+	endpointSlices, err := lister.EndpointSlices(namespace).List(selector)
 	if err != nil {
 		return nil, err
 	}
 
-	for _, endpoint := range endpoints {
+	result := make([]*discoveryv1.EndpointSlice, 0, len(endpointSlices))
+
+	for _, endpointSlice := range endpointSlices {
 		// for canary services default behavior is to disable them
-		// unless annotation envoy-control-plane/canary.enabled=true is set
-		if strings.HasSuffix(name, config.CanarySuffix) {
-			if isEnabled, ok := endpoint.Annotations[config.AnnotationCanaryEnabled]; !(ok && isEnabled == "true") {
-				continue
-			}
+		// unless annotation or service label envoy-control-plane/canary.enabled=true is set
+		if strings.HasSuffix(name, config.CanarySuffix) && !isCanaryEnabled(endpointSlice) {
+			continue
 		}
 
-		if endpoint.Name == name {
-			return endpoint, nil
-		}
+		result = append(result, endpointSlice)
+	}
+
+	if len(result) == 0 {
+		// nothing found
+		return nil, nil
+	}
+
+	return result, nil
+}
+
+// EndpointSlice controller copies service labels to slices, but not annotations.
+func isCanaryEnabled(endpointSlice *discoveryv1.EndpointSlice) bool {
+	if isEnabled, ok := endpointSlice.Annotations[config.AnnotationCanaryEnabled]; ok && isEnabled == "true" {
+		return true
+	}
+
+	if isEnabled, ok := endpointSlice.Labels[config.AnnotationCanaryEnabled]; ok && isEnabled == "true" {
+		return true
 	}
 
-	// nothing found
-	return nil, nil //nolint:nilnil
+	return false
 }
 
 func GetZoneByPodName(ctx context.Context, namespace string, pod string) string {
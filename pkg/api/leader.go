@@ -0,0 +1,41 @@
+/*
+Copyright paskal.maksim@gmail.com
+Licensed under the Apache License, Version 2.0 (the "License")
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package api
+
+import (
+	"github.com/maksim-paskal/envoy-control-plane/pkg/metrics"
+	"go.uber.org/atomic"
+)
+
+// only leader writes statuses, events and annotations to kubernetes.
+var isLeader = atomic.NewBool(false)
+
+func IsLeader() bool {
+	return isLeader.Load()
+}
+
+func SetLeader(leader bool) {
+	if isLeader.Swap(leader) == leader {
+		return
+	}
+
+	metrics.LeaderElectionLastTransition.SetToCurrentTime()
+
+	if leader {
+		metrics.LeaderElectionIsMaster.Set(1)
+		metrics.LeaderElectionTransitions.WithLabelValues("started_leading").Inc()
+	} else {
+		metrics.LeaderElectionIsMaster.Set(0)
+		metrics.LeaderElectionTransitions.WithLabelValues("stopped_leading").Inc()
+	}
+}
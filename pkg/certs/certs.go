@@ -106,19 +106,29 @@ func loadCAFromFiles() (*x509.Certificate, []byte, *rsa.PrivateKey, error) {
 		return nil, nil, nil, errors.Wrap(err, "can not load certicate")
 	}
 
+	keyBytes, err := os.ReadFile(*config.Get().SSLKey)
+	if err != nil {
+		return nil, nil, nil, errors.Wrap(err, "can not load key")
+	}
+
+	return parseCA(certBytes, keyBytes)
+}
+
+func parseCA(certBytes, keyBytes []byte) (*x509.Certificate, []byte, *rsa.PrivateKey, error) {
 	certBlock, _ := pem.Decode(certBytes)
+	if certBlock == nil {
+		return nil, nil, nil, errors.New("can not decode certicate")
+	}
 
 	cert, err := x509.ParseCertificate(certBlock.Bytes)
 	if err != nil {
 		return nil, nil, nil, errors.Wrap(err, "can not parse certicate")
 	}
 
-	keyBytes, err := os.ReadFile(*config.Get().SSLKey)
-	if err != nil {
-		return nil, nil, nil, errors.Wrap(err, "can not load key")
-	}
-
 	keyBlock, _ := pem.Decode(keyBytes)
+	if keyBlock == nil {
+		return nil, nil, nil, errors.New("can not decode key")
+	}
 
 	key, err := x509.ParsePKCS8PrivateKey(keyBlock.Bytes)
 	if err != nil {
@@ -134,6 +144,23 @@ func loadCAFromFiles() (*x509.Certificate, []byte, *rsa.PrivateKey, error) {
 	return cert, certBytes, privateKey, nil
 }
 
+// CA is generated when -ssl.crt and -ssl.key are not set.
+func IsGeneratedCA() bool {
+	return len(*config.Get().SSLCrt) == 0 || len(*config.Get().SSLKey) == 0
+}
+
+// replace loaded CA, used when CA is shared between replicas.
+func SetLoadedRoot(certBytes, keyBytes []byte) error {
+	cert, certBytes, key, err := parseCA(certBytes, keyBytes)
+	if err != nil {
+		return err
+	}
+
+	caCert, caCertBytes, caKey = cert, certBytes, key
+
+	return nil
+}
+
 func GenCARoot() (*x509.Certificate, []byte, *rsa.PrivateKey, []byte, error) {
 	rootTemplate := x509.Certificate{
 		SerialNumber: big.NewInt(1),
@@ -16,6 +16,8 @@ import (
 	"flag"
 	"fmt"
 	"os"
+	"regexp"
+	"strings"
 	"time"
 
 	"github.com/pkg/errors"
@@ -27,10 +29,32 @@ const (
 	AppName                      = "envoy-control-plane"
 	annotationRouteClusterWeight = AppName + "/routes.cluster.weight."
 	AnnotationCanaryEnabled      = AppName + "/canary.enabled"
+	AnnotationFragments          = AppName + "/fragments"
+	AnnotationRollbackEnabled    = AppName + "/rollback.enabled"
+	ConfigSourceConfigMap        = "ConfigMap"
+	ConfigSourceEnvoyNodeConfig  = "EnvoyNodeConfig"
 	CanarySuffix                 = "-canary"
+	ClusterLocalityRegion        = "region"
+	ClusterLocalitySubZone       = "sub_zone"
+	NodeHashID                   = "id"
+	NodeHashPrefix               = "prefix"
+	NodeHashRegex                = "regex"
+	NodeHashMetadata             = "metadata"
 	sslRotationPeriodDefault     = 1 * time.Hour
 	endpointCheckPeriodDefault   = 60 * time.Second
+	snapshotHistoryDefault       = 10
 	configDrainPeriodDefault     = 5 * time.Second
+	accessLogBufferDefault       = 10000
+	accessLogBatchSizeDefault    = 100
+	accessLogBatchDefault        = 1 * time.Second
+	accessLogFileMaxSizeDefault  = 100
+	accessLogFileBackupsDefault  = 5
+	accessLogOTLPTimeoutDefault  = 5 * time.Second
+	accessLogTailSizeDefault     = 1000
+	accessLogTailRingsDefault    = 100
+	logAccessMaxSizeDefault      = 100
+	logAccessBackupsDefault      = 5
+	logAccessMaxAgeDefault       = 7 * 24 * time.Hour
 	defaultGracePeriod           = 5 * time.Second
 )
 
@@ -40,13 +64,22 @@ type Type struct {
 	LogPretty             *bool          `yaml:"logPretty"`
 	LogAccess             *bool          `yaml:"logAccess"`
 	LogPath               *string        `yaml:"logPath"`
+	LogAccessMaxSize      *int           `yaml:"logAccessMaxSize"`
+	LogAccessBackups      *int           `yaml:"logAccessBackups"`
+	LogAccessMaxAge       *time.Duration `yaml:"logAccessMaxAge"`
+	LogAccessResources    *bool          `yaml:"logAccessResources"`
 	LogReportCaller       *bool          `yaml:"logReportCaller"`
 	ConfigFile            *string
 	ConfigMapLabels       *string        `yaml:"configMapLabels"`
 	ConfigMapNames        *string        `yaml:"configMapNames"`
+	EnvoyNodeConfig       *bool          `yaml:"envoyNodeConfig"`
 	KubeConfigFile        *string        `yaml:"kubeConfigFile"`
+	KubeConfigRemote      *string        `yaml:"kubeConfigRemote"`
+	ClusterName           *string        `yaml:"clusterName"`
+	ClusterLocality       *string        `yaml:"clusterLocality"`
 	WatchNamespaced       *bool          `yaml:"watchNamespaced"`
 	LeaderElection        *bool          `yaml:"leaderElection"`
+	ActiveActive          *bool          `yaml:"activeActive"`
 	PodName               *string        `yaml:"podName"`
 	Namespace             *string        `yaml:"namespace"`
 	GrpcAddress           *string        `yaml:"grpcAddress"`
@@ -59,25 +92,55 @@ type Type struct {
 	SSLName               *string        `yaml:"sslName"`
 	SSLCrt                *string        `yaml:"sslCrt"`
 	SSLKey                *string        `yaml:"sslKey"`
+	SSLSecret             *string        `yaml:"sslSecret"`
 	SSLDoNotUseValidation *bool          `yaml:"sslDoNotUseValidation"`
 	SSLRotationPeriod     *time.Duration `yaml:"sslRotationPeriod"`
 	WebAdminUser          *string        `yaml:"webAdminUser"`
 	WebAdminPassword      *string        `yaml:"webAdminPassword"`
+	SnapshotStore         *string        `yaml:"snapshotStore"`
+	SnapshotStorePath     *string        `yaml:"snapshotStorePath"`
+	SnapshotHistory       *int           `yaml:"snapshotHistory"`
+	NodeHash              *string        `yaml:"nodeHash"`
+	NodeHashRegex         *string        `yaml:"nodeHashRegex"`
+	NodeHashMetadata      *string        `yaml:"nodeHashMetadata"`
+	AccessLogSinks        *string        `yaml:"accessLogSinks"`
+	AccessLogFields       *string        `yaml:"accessLogFields"`
+	AccessLogBuffer       *int           `yaml:"accessLogBuffer"`
+	AccessLogBatchSize    *int           `yaml:"accessLogBatchSize"`
+	AccessLogBatchPeriod  *time.Duration `yaml:"accessLogBatchPeriod"`
+	AccessLogBackpressure *string        `yaml:"accessLogBackpressure"`
+	AccessLogFile         *string        `yaml:"accessLogFile"`
+	AccessLogFileMaxSize  *int           `yaml:"accessLogFileMaxSize"`
+	AccessLogFileBackups  *int           `yaml:"accessLogFileBackups"`
+	AccessLogOTLPEndpoint *string        `yaml:"accessLogOtlpEndpoint"`
+	AccessLogOTLPTimeout  *time.Duration `yaml:"accessLogOtlpTimeout"`
+	AccessLogMetrics      *bool          `yaml:"accessLogMetrics"`
+	AccessLogTailSize     *int           `yaml:"accessLogTailSize"`
+	AccessLogTailRings    *int           `yaml:"accessLogTailRings"`
 }
 
 var config = Type{
 	GracePeriod:           flag.Duration("grace-period", defaultGracePeriod, "grace period"),
 	LogLevel:              flag.String("log.level", "INFO", "log level"),
 	LogPretty:             flag.Bool("log.pretty", false, "log in pretty format"),
-	LogAccess:             flag.Bool("log.access", false, "access log"),
-	LogPath:               flag.String("log.path", "/tmp", "access log path"),
+	LogAccess:             flag.Bool("log.access", false, "xDS audit log of discovery requests and responses"),
+	LogPath:               flag.String("log.path", "/tmp", "directory of xDS audit log"),
+	LogAccessMaxSize:      flag.Int("log.access.maxSize", logAccessMaxSizeDefault, "max size of xDS audit log in megabytes before rotation"), //nolint:lll
+	LogAccessBackups:      flag.Int("log.access.backups", logAccessBackupsDefault, "count of rotated xDS audit logs"),
+	LogAccessMaxAge:       flag.Duration("log.access.maxAge", logAccessMaxAgeDefault, "rotated xDS audit logs older than max age are deleted, 0 to disable"), //nolint:lll
+	LogAccessResources:    flag.Bool("log.access.resources", false, "include resources of discovery responses in xDS audit log"),                             //nolint:lll
 	LogReportCaller:       flag.Bool("log.reportCaller", true, "log file name and line number"),
 	ConfigFile:            flag.String("config", getEnvDefault("CONFIG", "config.yaml"), "load config from file"),
 	ConfigMapLabels:       flag.String("configmap.labels", "app=envoy-control-plane", "config directory"),
 	ConfigMapNames:        flag.String("configmap.names", "", "name of configmap to import, comma separated"),
+	EnvoyNodeConfig:       flag.Bool("envoynodeconfig", false, "load configs from EnvoyNodeConfig custom resources"),
 	KubeConfigFile:        flag.String("kubeconfig.path", "", "kubeconfig path"),
+	KubeConfigRemote:      flag.String("kubeconfig.remote", "", "remote clusters kubeconfigs, comma separated name=path"),
+	ClusterName:           flag.String("cluster.name", "local", "name of current kubernetes cluster"),
+	ClusterLocality:       flag.String("cluster.locality", ClusterLocalitySubZone, "locality field for kubernetes cluster name, region or sub_zone"), //nolint:lll
 	WatchNamespaced:       flag.Bool("namespaced", true, "watch pod in one namespace"),
 	LeaderElection:        flag.Bool("leaderElection", true, "leader election"),
+	ActiveActive:          flag.Bool("activeActive", true, "every replica serves xDS, leader only writes statuses and events"), //nolint:lll
 	PodName:               flag.String("pod", os.Getenv("MY_POD_NAME"), "name of pod"),
 	Namespace:             flag.String("namespace", getEnvDefault("MY_POD_NAMESPACE", "default"), "watch namespace"),
 	GrpcAddress:           flag.String("grpc.address", ":18080", "grpc address"),
@@ -90,10 +153,31 @@ var config = Type{
 	SSLName:               flag.String("ssl.name", "envoy_control_plane_default", "name of certificate in envoy secrets"), //nolint:lll
 	SSLCrt:                flag.String("ssl.crt", "", "path to CA cert"),
 	SSLKey:                flag.String("ssl.key", "", "path to CA key"),
+	SSLSecret:             flag.String("ssl.secret", AppName+"-ca", "secret with generated CA that is shared by all replicas, empty to disable"), //nolint:lll
 	SSLRotationPeriod:     flag.Duration("ssl.rotation", sslRotationPeriodDefault, "period of certificate rotation"),
 	SSLDoNotUseValidation: flag.Bool("ssl.no-validation", false, "do not use validation. Only for development"),
 	WebAdminUser:          flag.String("web.adminUser", "admin", "basic auth user for admin endpoints"),
 	WebAdminPassword:      flag.String("web.adminPassword", GetVersion(), "basic auth password for admin endpoints"),
+	NodeHash:              flag.String("node.hash", NodeHashID, "node id to config mapping: id, prefix, regex or metadata"),
+	NodeHashRegex:         flag.String("node.hash.regex", "", "regex with group of config id for regex node hash"),
+	NodeHashMetadata:      flag.String("node.hash.metadata", "", "node metadata field with config id for metadata node hash"),
+	SnapshotStore:         flag.String("snapshot.store", "", "store last snapshots in file or secret, empty to disable"),
+	SnapshotStorePath:     flag.String("snapshot.path", "", "path to private directory with snapshots for file store"),
+	SnapshotHistory:       flag.Int("snapshot.history", snapshotHistoryDefault, "count of pushed snapshots in history of every node"),
+	AccessLogSinks:        flag.String("accesslog.sinks", "log", "envoy access log sinks, comma separated: log, stdout, file, otlp"),
+	AccessLogFields:       flag.String("accesslog.fields", "", "envoy access log entry fields, comma separated json paths, empty for all fields"), //nolint:lll
+	AccessLogBuffer:       flag.Int("accesslog.buffer", accessLogBufferDefault, "count of access log entries waiting for sinks"),
+	AccessLogBatchSize:    flag.Int("accesslog.batch.size", accessLogBatchSizeDefault, "max count of access log entries in one write to sinks"),      //nolint:lll
+	AccessLogBatchPeriod:  flag.Duration("accesslog.batch.period", accessLogBatchDefault, "max time before access log entries are written to sinks"), //nolint:lll
+	AccessLogBackpressure: flag.String("accesslog.backpressure", "drop", "when buffer is full: drop entries or block envoy stream"),                  //nolint:lll
+	AccessLogFile:         flag.String("accesslog.file", "/tmp/envoy-access.log", "path to file of file sink"),
+	AccessLogFileMaxSize:  flag.Int("accesslog.file.maxSize", accessLogFileMaxSizeDefault, "max size of file in megabytes before rotation"), //nolint:lll
+	AccessLogFileBackups:  flag.Int("accesslog.file.backups", accessLogFileBackupsDefault, "count of rotated files"),
+	AccessLogOTLPEndpoint: flag.String("accesslog.otlp.endpoint", "http://127.0.0.1:4318/v1/logs", "OTLP/HTTP logs endpoint of otlp sink"), //nolint:lll
+	AccessLogOTLPTimeout:  flag.Duration("accesslog.otlp.timeout", accessLogOTLPTimeoutDefault, "timeout of OTLP/HTTP request"),
+	AccessLogTailSize:     flag.Int("accesslog.tail.size", accessLogTailSizeDefault, "count of recent access log entries of every node and log name for tail, 0 to disable"),       //nolint:lll
+	AccessLogTailRings:    flag.Int("accesslog.tail.rings", accessLogTailRingsDefault, "max count of nodes and log names with recent entries, least recently updated are removed"), //nolint:lll
+	AccessLogMetrics:      flag.Bool("accesslog.metrics", true, "requests count, duration and response codes metrics from envoy access logs"),                                      //nolint:lll
 }
 
 func Load() error {
@@ -117,7 +201,11 @@ func Get() *Type {
 }
 
 func String() string {
-	out, err := yaml.Marshal(config)
+	redacted := config
+	redactedPassword := "[redacted]"
+	redacted.WebAdminPassword = &redactedPassword
+
+	out, err := yaml.Marshal(redacted)
 	if err != nil {
 		return fmt.Sprintf("ERROR: %t", err)
 	}
@@ -132,6 +220,18 @@ func CheckConfig() error {
 		}
 	}
 
+	if _, err := ParseRemoteKubeConfigs(*config.KubeConfigRemote); err != nil {
+		return errors.Wrap(err, "remote kubeconfig error")
+	}
+
+	if *config.ClusterLocality != ClusterLocalityRegion && *config.ClusterLocality != ClusterLocalitySubZone {
+		return errClusterLocality
+	}
+
+	if err := checkNodeHash(); err != nil {
+		return err
+	}
+
 	if len(*config.SSLCrt) > 0 {
 		if _, err := os.Stat(*config.SSLCrt); os.IsNotExist(err) {
 			return errors.Wrap(err, "ssl certificate error")
@@ -144,9 +244,66 @@ func CheckConfig() error {
 		}
 	}
 
+	// snapshots contain certificates of envoy, path must be set explicitly
+	if *config.SnapshotStore == "file" && len(*config.SnapshotStorePath) == 0 {
+		return errSnapshotStorePath
+	}
+
+	return nil
+}
+
+func checkNodeHash() error {
+	switch *config.NodeHash {
+	case NodeHashID, NodeHashPrefix:
+	case NodeHashRegex:
+		re, err := regexp.Compile(*config.NodeHashRegex)
+		if err != nil {
+			return errors.Wrap(err, "node hash regex error")
+		}
+
+		if re.NumSubexp() == 0 {
+			return errNodeHashRegex
+		}
+	case NodeHashMetadata:
+		if len(*config.NodeHashMetadata) == 0 {
+			return errNodeHashMetadata
+		}
+	default:
+		return errors.Wrap(errNodeHash, *config.NodeHash)
+	}
+
 	return nil
 }
 
+// parse remote kubeconfigs in format name1=path1,name2=path2.
+func ParseRemoteKubeConfigs(value string) (map[string]string, error) {
+	result := make(map[string]string)
+
+	if len(value) == 0 {
+		return result, nil
+	}
+
+	for _, item := range strings.Split(value, ",") {
+		nameAndPath := strings.SplitN(strings.TrimSpace(item), "=", 2) //nolint:gomnd
+
+		if len(nameAndPath) != 2 || len(nameAndPath[0]) == 0 || len(nameAndPath[1]) == 0 { //nolint:gomnd
+			return nil, errors.Wrap(errRemoteKubeConfig, item)
+		}
+
+		if nameAndPath[0] == *config.ClusterName {
+			return nil, errors.Wrap(errRemoteKubeConfigName, item)
+		}
+
+		if _, ok := result[nameAndPath[0]]; ok {
+			return nil, errors.Wrap(errRemoteKubeConfigName, item)
+		}
+
+		result[nameAndPath[0]] = nameAndPath[1]
+	}
+
+	return result, nil
+}
+
 var gitVersion = "dev"
 
 func GetVersion() string {
@@ -167,21 +324,3 @@ func getEnvDefault(name string, defaultValue string) string {
 
 	return r
 }
-
-func ValidateConfig(path string) error {
-	configBytes, err := os.ReadFile(path)
-	if err != nil {
-		log.Fatal(err)
-	}
-
-	config := ConfigType{}
-	if err := yaml.Unmarshal(configBytes, &config); err != nil {
-		log.Fatal(err)
-	}
-
-	if err := config.SaveResources(); err != nil {
-		log.Fatal(err)
-	}
-
-	return nil
-}
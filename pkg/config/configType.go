@@ -13,11 +13,8 @@ limitations under the License.
 package config
 
 import (
-	"bytes"
-	"path"
 	"strconv"
 	"strings"
-	"text/template"
 
 	cluster "github.com/envoyproxy/go-control-plane/envoy/config/cluster/v3"
 	core "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
@@ -28,7 +25,6 @@ import (
 	"github.com/envoyproxy/go-control-plane/pkg/cache/types"
 	"github.com/envoyproxy/go-control-plane/pkg/wellknown"
 	"github.com/maksim-paskal/envoy-control-plane/pkg/resources"
-	"github.com/maksim-paskal/utils-go"
 	"github.com/pkg/errors"
 	log "github.com/sirupsen/logrus"
 	"google.golang.org/protobuf/types/known/anypb"
@@ -44,10 +40,42 @@ type KubernetesType struct {
 	Priority        uint32            `yaml:"priority"`
 	Selector        map[string]string `yaml:"selector"`
 	Service         string            `yaml:"service"`
+	// publish not ready endpoints with UNHEALTHY health status
+	PublishNotReady bool `yaml:"publishnotready"`
+	// publish terminating endpoints that still serving with DRAINING health status
+	PublishTerminating bool `yaml:"publishterminating"`
+	// kubernetes clusters to load endpoints from, default is current cluster
+	Clusters []KubernetesClusterType `yaml:"clusters"`
+}
+
+type KubernetesClusterType struct {
+	// name of cluster from -cluster.name or -kubeconfig.remote
+	Name string `yaml:"name"`
+	// priority of cluster endpoints, default is priority of kubernetes entry
+	Priority uint32 `yaml:"priority"`
+}
+
+// return clusters of kubernetes entry, current cluster if clusters not set.
+func (k *KubernetesType) GetClusters() []KubernetesClusterType {
+	if len(k.Clusters) == 0 {
+		return []KubernetesClusterType{{Name: *Get().ClusterName}}
+	}
+
+	return k.Clusters
 }
 
 type ConfigType struct { //nolint: revive
 	ID string `yaml:"id"`
+	// render config for every connected envoy with node data
+	PerNode bool `yaml:"pernode"`
+	// config template, used to render per node configs
+	ConfigTemplate string `yaml:"-"`
+	// config template name
+	ConfigTemplateName string `yaml:"-"`
+	// fragments to extend config, configmap/key or namespace/configmap/key
+	Extends []string `yaml:"extends"`
+	// used fragments by namespace/configmap/key
+	Fragments map[string]string `yaml:"-"`
 	// used in certificate section common name
 	Name string `yaml:"name"`
 	// add version to node name
@@ -56,12 +84,16 @@ type ConfigType struct { //nolint: revive
 	VersionLabelKey string `yaml:"versionlabelkey"`
 	// version value
 	VersionLabel string
-	// source configmap name
+	// source kind, ConfigMap or EnvoyNodeConfig
+	ConfigSourceKind string
+	// source configmap or EnvoyNodeConfig name
 	ConfigMapName string
-	// source configmap namespace
+	// source configmap or EnvoyNodeConfig namespace
 	ConfigMapNamespace string
 	// source configmap annotations
 	ConfigMapAnnotations map[string]string
+	// source configmap or EnvoyNodeConfig resourceVersion
+	ConfigMapResourceVersion string
 	// kubernetes endpoints
 	Kubernetes []KubernetesType `yaml:"kubernetes"`
 	// config.endpoint.v3.ClusterLoadAssignment
@@ -90,6 +122,11 @@ func (c *ConfigType) HasClusterWeights() bool {
 	return false
 }
 
+// rollback to last acknowledged snapshot when envoy rejects config.
+func (c *ConfigType) IsRollbackEnabled() bool {
+	return c.ConfigMapAnnotations[AnnotationRollbackEnabled] == "true"
+}
+
 func (c *ConfigType) GetClusters() []types.Resource {
 	return c.clusters
 }
@@ -173,21 +210,46 @@ func (c *ConfigType) SaveResources() error {
 }
 
 func ParseConfigYaml(nodeID string, text string, data interface{}) (*ConfigType, error) {
-	t := template.New(nodeID)
-	templates := template.Must(t.Funcs(utils.GoTemplateFunc(t)).Parse(text))
+	return ParseConfigMapYaml("", nodeID, text, data)
+}
 
-	var tpl bytes.Buffer
+// parse ConfigMap key, fragments without namespace are loaded from ConfigMap namespace.
+func ParseConfigMapYaml(namespace, nodeID, text string, data interface{}) (*ConfigType, error) {
+	renderer := newConfigRenderer(namespace, data)
 
-	err := templates.ExecuteTemplate(&tpl, path.Base(nodeID), data)
+	result, err := renderer.render(nodeID, text)
 	if err != nil {
-		return nil, errors.Wrap(err, "templates.ExecuteTemplate")
+		return nil, err
 	}
 
+	config, err := NewConfigFromYaml(result)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := renderer.applyExtends(config); err != nil {
+		return nil, errors.Wrap(err, "error in extends")
+	}
+
+	config.ConfigTemplate = text
+	config.ConfigTemplateName = nodeID
+	config.Fragments = renderer.fragments
+
+	return config, nil
+}
+
+// render ConfigMap key template without parsing.
+func RenderConfigMapYaml(namespace, nodeID, text string, data interface{}) ([]byte, error) {
+	return newConfigRenderer(namespace, data).render(nodeID, text)
+}
+
+// parse config without templating.
+func NewConfigFromYaml(data []byte) (*ConfigType, error) {
 	config := ConfigType{
 		VersionLabelKey: "version",
 	}
 
-	err = yaml.Unmarshal(tpl.Bytes(), &config)
+	err := yaml.Unmarshal(data, &config)
 	if err != nil {
 		return nil, errors.Wrap(err, "yaml.Unmarshal")
 	}
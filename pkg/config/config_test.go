@@ -15,7 +15,10 @@ package config_test
 import (
 	"testing"
 
+	cluster "github.com/envoyproxy/go-control-plane/envoy/config/cluster/v3"
+	core "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
 	"github.com/maksim-paskal/envoy-control-plane/pkg/config"
+	"google.golang.org/protobuf/types/known/structpb"
 )
 
 func TestConfig(t *testing.T) {
@@ -29,3 +32,114 @@ func TestConfig(t *testing.T) {
 		t.Fatalf("KubeConfigFile != %s", want)
 	}
 }
+
+func TestParseRemoteKubeConfigs(t *testing.T) {
+	t.Parallel()
+
+	remote, err := config.ParseRemoteKubeConfigs("eu-west=/config/eu-west, us-east=/config/us-east")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if want := "/config/us-east"; remote["us-east"] != want {
+		t.Fatalf("us-east != %s", want)
+	}
+
+	for _, value := range []string{"eu-west", "=/config/eu-west", "eu-west=/a,eu-west=/b", "local=/config/local"} {
+		if _, err := config.ParseRemoteKubeConfigs(value); err == nil {
+			t.Fatalf("%s must be invalid", value)
+		}
+	}
+}
+
+func TestParseConfigYamlNodeData(t *testing.T) {
+	t.Parallel()
+
+	node := &core.Node{
+		Id:       "test-id",
+		Locality: &core.Locality{Zone: "zone-a"},
+		Metadata: &structpb.Struct{Fields: map[string]*structpb.Value{
+			"POD_NAME": structpb.NewStringValue("test-pod"),
+		}},
+	}
+
+	text := `name: {{ .Node.Metadata.POD_NAME }}-{{ .Node.Zone }}`
+
+	c, err := config.ParseConfigYaml("test-id", text, config.NewTemplateData(node))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if want := "test-pod-zone-a"; c.Name != want {
+		t.Fatalf("name %s != %s", c.Name, want)
+	}
+
+	if c.ConfigTemplate != text {
+		t.Fatal("config template not saved")
+	}
+
+	if _, err := config.ParseConfigYaml("test-id", "{{ .Node.ID", nil); err == nil {
+		t.Fatal("invalid template must return error")
+	}
+}
+
+func TestParseConfigMapYamlFragments(t *testing.T) {
+	t.Parallel()
+
+	config.SetFragments("fragments-test", "shared", map[string]string{
+		"clusters": `
+clusters:
+- name: service-a
+  connect_timeout: 1s
+- name: service-b
+  connect_timeout: 1s
+kubernetes:
+- cluster_name: service-a
+  port: 8000`,
+		"name": `{{ .Node.ID }}-name`,
+	})
+
+	text := `
+name: {{ fragment "shared/name" }}
+extends:
+- fragments-test/shared/clusters
+clusters:
+- name: service-b
+  connect_timeout: 2s`
+
+	c, err := config.ParseConfigMapYaml("fragments-test", "test-id", text, config.NewTemplateData(&core.Node{Id: "test-id"})) //nolint:lll
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if want := "test-id-name"; c.Name != want {
+		t.Fatalf("name %s != %s", c.Name, want)
+	}
+
+	if len(c.Clusters) != 2 || len(c.Kubernetes) != 1 {
+		t.Fatalf("fragment not merged, clusters=%d, kubernetes=%d", len(c.Clusters), len(c.Kubernetes))
+	}
+
+	if err := c.SaveResources(); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, item := range c.GetClusters() {
+		c, ok := item.(*cluster.Cluster)
+		if !ok {
+			t.Fatal("not a cluster")
+		}
+
+		if c.GetName() == "service-b" && c.GetConnectTimeout().GetSeconds() != 2 {
+			t.Fatal("config must override fragment")
+		}
+	}
+
+	if !c.UsesFragments("fragments-test", "shared") {
+		t.Fatal("used fragments not saved")
+	}
+
+	if _, err := config.ParseConfigMapYaml("fragments-test", "test-id", "extends: [shared/unknown]", nil); err == nil {
+		t.Fatal("unknown fragment must return error")
+	}
+}
@@ -14,4 +14,16 @@ package config
 
 import "errors"
 
-var errUseNamespace = errors.New("use namespace name if using namespaced")
+var (
+	errUseNamespace         = errors.New("use namespace name if using namespaced")
+	errClusterLocality      = errors.New("cluster locality must be region or sub_zone")
+	errRemoteKubeConfig     = errors.New("remote kubeconfig must be in format name=path")
+	errRemoteKubeConfigName = errors.New("remote cluster name must be unique")
+	errNodeHash             = errors.New("unknown node hash")
+	errNodeHashRegex        = errors.New("node hash regex must have group with config id")
+	errNodeHashMetadata     = errors.New("node hash metadata field is not set")
+	errFragmentRef          = errors.New("fragment must be configmap/key or namespace/configmap/key")
+	errFragmentNotFound     = errors.New("fragment not found")
+	errFragmentDepth        = errors.New("fragments nesting is too deep, check for cycles")
+	errSnapshotStorePath    = errors.New("snapshot path must be set for file store")
+)
@@ -0,0 +1,280 @@
+/*
+Copyright paskal.maksim@gmail.com
+Licensed under the Apache License, Version 2.0 (the "License")
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package config
+
+import (
+	"bytes"
+	"path"
+	"strings"
+	"sync"
+	"text/template"
+
+	"github.com/maksim-paskal/utils-go"
+	"github.com/pkg/errors"
+)
+
+const maxFragmentDepth = 10
+
+// fragments from ConfigMaps with fragments annotation by namespace/configmap/key.
+var fragments = new(sync.Map)
+
+// replace all fragments of ConfigMap.
+func SetFragments(namespace, name string, data map[string]string) {
+	DeleteFragments(namespace, name)
+
+	for key, text := range data {
+		fragments.Store(namespace+"/"+name+"/"+key, text)
+	}
+}
+
+func DeleteFragments(namespace, name string) {
+	prefix := namespace + "/" + name + "/"
+
+	fragments.Range(func(key, _ interface{}) bool {
+		if ref, ok := key.(string); ok && strings.HasPrefix(ref, prefix) {
+			fragments.Delete(key)
+		}
+
+		return true
+	})
+}
+
+// full reference of fragment, ref must be configmap/key or namespace/configmap/key.
+func FragmentRef(namespace, ref string) (string, error) {
+	switch len(strings.Split(ref, "/")) {
+	case 2: //nolint:gomnd
+		return namespace + "/" + ref, nil
+	case 3: //nolint:gomnd
+		return ref, nil
+	default:
+		return "", errors.Wrap(errFragmentRef, ref)
+	}
+}
+
+// returns true if config uses fragments of ConfigMap.
+func (c *ConfigType) UsesFragments(namespace, name string) bool {
+	prefix := namespace + "/" + name + "/"
+
+	for ref := range c.Fragments {
+		if strings.HasPrefix(ref, prefix) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// merge fragments from extends into config, config values override fragment values.
+func (c *ConfigType) ApplyExtends(namespace string, data interface{}) error {
+	renderer := newConfigRenderer(namespace, data)
+
+	if err := renderer.applyExtends(c); err != nil {
+		return err
+	}
+
+	c.Fragments = renderer.fragments
+
+	return nil
+}
+
+// renders config templates and collects used fragments.
+type configRenderer struct {
+	namespace string
+	data      interface{}
+	fragments map[string]string
+	depth     int
+}
+
+func newConfigRenderer(namespace string, data interface{}) *configRenderer {
+	return &configRenderer{
+		namespace: namespace,
+		data:      data,
+		fragments: make(map[string]string),
+	}
+}
+
+func (r *configRenderer) render(name, text string) ([]byte, error) {
+	if r.depth > maxFragmentDepth {
+		return nil, errFragmentDepth
+	}
+
+	r.depth++
+	defer func() { r.depth-- }()
+
+	t := template.New(path.Base(name))
+
+	funcs := utils.GoTemplateFunc(t)
+	funcs["fragment"] = r.includeFragment
+
+	templates, err := t.Funcs(funcs).Parse(text)
+	if err != nil {
+		return nil, errors.Wrap(err, "template.Parse")
+	}
+
+	var tpl bytes.Buffer
+
+	if err := templates.Execute(&tpl, r.data); err != nil {
+		return nil, errors.Wrap(err, "templates.ExecuteTemplate")
+	}
+
+	return tpl.Bytes(), nil
+}
+
+func (r *configRenderer) loadFragment(ref string) (string, string, error) {
+	fullRef, err := FragmentRef(r.namespace, ref)
+	if err != nil {
+		return "", "", err
+	}
+
+	value, ok := fragments.Load(fullRef)
+	if !ok {
+		return "", "", errors.Wrap(errFragmentNotFound, fullRef)
+	}
+
+	text, ok := value.(string)
+	if !ok {
+		return "", "", errors.Wrap(errFragmentNotFound, fullRef)
+	}
+
+	r.fragments[fullRef] = text
+
+	return fullRef, text, nil
+}
+
+// template function, returns rendered fragment text.
+func (r *configRenderer) includeFragment(ref string) (string, error) {
+	fullRef, text, err := r.loadFragment(ref)
+	if err != nil {
+		return "", err
+	}
+
+	result, err := r.render(fullRef, text)
+	if err != nil {
+		return "", errors.Wrapf(err, "fragment %s", fullRef)
+	}
+
+	return string(result), nil
+}
+
+func (r *configRenderer) applyExtends(config *ConfigType) error {
+	if len(config.Extends) == 0 {
+		return nil
+	}
+
+	if r.depth > maxFragmentDepth {
+		return errFragmentDepth
+	}
+
+	r.depth++
+	defer func() { r.depth-- }()
+
+	base := &ConfigType{}
+
+	// later fragments override earlier
+	for _, ref := range config.Extends {
+		fullRef, text, err := r.loadFragment(ref)
+		if err != nil {
+			return err
+		}
+
+		result, err := r.render(fullRef, text)
+		if err != nil {
+			return errors.Wrapf(err, "fragment %s", fullRef)
+		}
+
+		fragment, err := NewConfigFromYaml(result)
+		if err != nil {
+			return errors.Wrapf(err, "fragment %s", fullRef)
+		}
+
+		if err := r.applyExtends(fragment); err != nil {
+			return err
+		}
+
+		fragment.extend(base)
+
+		base = fragment
+	}
+
+	config.extend(base)
+
+	return nil
+}
+
+// add resources of base config that are not defined in config.
+func (c *ConfigType) extend(base *ConfigType) {
+	c.Clusters = mergeItems(base.Clusters, c.Clusters, "name")
+	c.Routes = mergeItems(base.Routes, c.Routes, "name")
+	c.Listeners = mergeItems(base.Listeners, c.Listeners, "name")
+	c.Secrets = mergeItems(base.Secrets, c.Secrets, "name")
+	c.Endpoints = mergeItems(base.Endpoints, c.Endpoints, "cluster_name")
+
+	kubernetes := make([]KubernetesType, 0, len(base.Kubernetes)+len(c.Kubernetes))
+	index := make(map[string]int)
+
+	for _, items := range [][]KubernetesType{base.Kubernetes, c.Kubernetes} {
+		for _, item := range items {
+			if i, ok := index[item.ClusterName]; ok {
+				kubernetes[i] = item
+
+				continue
+			}
+
+			index[item.ClusterName] = len(kubernetes)
+			kubernetes = append(kubernetes, item)
+		}
+	}
+
+	c.Kubernetes = kubernetes
+
+	if c.Validation == nil {
+		c.Validation = base.Validation
+	}
+}
+
+// items of override replace items of base with the same key.
+func mergeItems(base, override []interface{}, key string) []interface{} {
+	if len(base) == 0 {
+		return override
+	}
+
+	result := make([]interface{}, 0, len(base)+len(override))
+	index := make(map[string]int)
+
+	for _, items := range [][]interface{}{base, override} {
+		for _, item := range items {
+			name := getItemKey(item, key)
+
+			if i, ok := index[name]; ok && len(name) > 0 {
+				result[i] = item
+
+				continue
+			}
+
+			index[name] = len(result)
+			result = append(result, item)
+		}
+	}
+
+	return result
+}
+
+func getItemKey(item interface{}, key string) string {
+	if m, ok := item.(map[string]interface{}); ok {
+		if value, ok := m[key].(string); ok {
+			return value
+		}
+	}
+
+	return ""
+}
@@ -0,0 +1,70 @@
+/*
+Copyright paskal.maksim@gmail.com
+Licensed under the Apache License, Version 2.0 (the "License")
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package config
+
+import (
+	"fmt"
+
+	core "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
+)
+
+// data for config templates.
+type TemplateData struct {
+	Node TemplateNode
+}
+
+// connecting envoy node, empty when config rendered without node.
+type TemplateNode struct {
+	ID               string
+	Cluster          string
+	Region           string
+	Zone             string
+	SubZone          string
+	Metadata         map[string]interface{}
+	UserAgentName    string
+	UserAgentVersion string
+}
+
+func NewTemplateData(node *core.Node) *TemplateData {
+	data := TemplateData{
+		Node: TemplateNode{
+			Metadata: make(map[string]interface{}),
+		},
+	}
+
+	if node == nil {
+		return &data
+	}
+
+	data.Node.ID = node.GetId()
+	data.Node.Cluster = node.GetCluster()
+	data.Node.Region = node.GetLocality().GetRegion()
+	data.Node.Zone = node.GetLocality().GetZone()
+	data.Node.SubZone = node.GetLocality().GetSubZone()
+	data.Node.UserAgentName = node.GetUserAgentName()
+	data.Node.UserAgentVersion = node.GetUserAgentVersion()
+
+	if buildVersion := node.GetUserAgentBuildVersion().GetVersion(); buildVersion != nil {
+		data.Node.UserAgentVersion = fmt.Sprintf("%d.%d.%d",
+			buildVersion.GetMajorNumber(),
+			buildVersion.GetMinorNumber(),
+			buildVersion.GetPatch(),
+		)
+	}
+
+	if node.GetMetadata() != nil {
+		data.Node.Metadata = node.GetMetadata().AsMap()
+	}
+
+	return &data
+}
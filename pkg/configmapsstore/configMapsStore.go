@@ -19,9 +19,12 @@ import (
 	"sync"
 	"time"
 
-	"github.com/maksim-paskal/envoy-control-plane/pkg/config"
+	"github.com/maksim-paskal/envoy-control-plane/pkg/api"
+	appConfig "github.com/maksim-paskal/envoy-control-plane/pkg/config"
 	"github.com/maksim-paskal/envoy-control-plane/pkg/configstore"
 	"github.com/maksim-paskal/envoy-control-plane/pkg/controlplane"
+	"github.com/maksim-paskal/envoy-control-plane/pkg/snapshotstore"
+	"github.com/maksim-paskal/envoy-control-plane/pkg/utils"
 	"github.com/pkg/errors"
 	log "github.com/sirupsen/logrus"
 	v1 "k8s.io/api/core/v1"
@@ -31,8 +34,8 @@ var mutex sync.Mutex
 
 func checkConfigMapLabels(cm *v1.ConfigMap) bool {
 	// if config has exact names, it only loads them
-	if len(*config.Get().ConfigMapNames) != 0 {
-		for _, name := range strings.Split(*config.Get().ConfigMapNames, ",") {
+	if len(*appConfig.Get().ConfigMapNames) != 0 {
+		for _, name := range strings.Split(*appConfig.Get().ConfigMapNames, ",") {
 			if cm.Name == name {
 				return true
 			}
@@ -41,7 +44,7 @@ func checkConfigMapLabels(cm *v1.ConfigMap) bool {
 		return false
 	}
 
-	label := strings.Split(*config.Get().ConfigMapLabels, "=")
+	label := strings.Split(*appConfig.Get().ConfigMapLabels, "=")
 
 	return (cm.Labels[label[0]] == label[1])
 }
@@ -54,83 +57,254 @@ func NewConfigMap(ctx context.Context, cm *v1.ConfigMap) error {
 	mutex.Lock()
 	defer mutex.Unlock()
 
+	if isFragmentsConfigMap(cm) {
+		applyFragments(ctx, cm)
+
+		return nil
+	}
+
+	return newConfigMap(ctx, cm)
+}
+
+func newConfigMap(ctx context.Context, cm *v1.ConfigMap) error {
+	var (
+		result    error
+		isApplied bool
+	)
+
+	// every key is separate node config, invalid key must not affect other nodes
 	for nodeID, text := range cm.Data {
-		config, err := config.ParseConfigYaml(nodeID, text, nil)
+		appliedNodeID, applied, err := newConfigMapKey(ctx, cm, nodeID, text)
 		if err != nil {
-			return err
+			err = errors.Wrapf(err, "configmap %s/%s key %s rejected", cm.Namespace, cm.Name, nodeID)
+
+			source := appConfig.ConfigSourceConfigMap + "/" + cm.Namespace + "/" + cm.Name
+
+			configstore.SetConfigError(appliedNodeID, source, err)
+			api.Event(cm, v1.EventTypeWarning, api.EventReasonConfigRejected, err.Error())
+
+			result = err
+
+			continue
 		}
 
-		if len(config.ID) == 0 {
-			config.ID = nodeID
+		configstore.ClearConfigError(appliedNodeID)
+
+		if applied {
+			isApplied = true
 		}
+	}
 
-		if len(config.Name) == 0 {
-			config.Name = config.ID
+	if isApplied {
+		if err := annotateConfigMap(ctx, cm); err != nil {
+			log.WithError(err).Error("error annotating ConfigMap")
 		}
+	}
 
-		config.ConfigMapName = cm.Name
-		config.ConfigMapNamespace = cm.Namespace
-		config.ConfigMapAnnotations = cm.Annotations
+	return result
+}
 
-		if config.UseVersionLabel && len(cm.Labels[config.VersionLabelKey]) > 0 {
-			log.Debug("update Id, using UseVersionLabel")
+// save last applied version and time in ConfigMap annotations.
+func annotateConfigMap(ctx context.Context, cm *v1.ConfigMap) error {
+	configHashes := make(map[string]string)
 
-			config.VersionLabel = cm.Labels[config.VersionLabelKey]
-			config.ID = fmt.Sprintf("%s-%s", config.ID, config.VersionLabel)
-		}
+	for _, cs := range getConfigSourceStores(appConfig.ConfigSourceConfigMap, cm.Namespace, cm.Name) {
+		configHashes[cs.Config.ID] = cs.ConfigHash
+	}
 
-		for i := 0; i < len(config.Kubernetes); i++ {
-			if len(config.Kubernetes[i].Namespace) == 0 {
-				log.Debug("namespace not set - using configmap namespace")
+	version, err := utils.GetJSONHash(configHashes)
+	if err != nil {
+		return errors.Wrap(err, "error in utils.GetJSONHash")
+	}
 
-				config.Kubernetes[i].Namespace = cm.Namespace
-			}
-		}
+	return api.PatchConfigMapAnnotations(ctx, cm.Namespace, cm.Name, map[string]string{
+		api.AnnotationLastAppliedVersion: version,
+		api.AnnotationLastAppliedTime:    time.Now().UTC().Format(time.RFC3339),
+	})
+}
 
-		if v, ok := configstore.StoreMap.Load(config.ID); ok {
-			cs, ok := v.(*configstore.ConfigStore)
+// parse configmap key and save config, returns nodeID of config and true if config was applied.
+func newConfigMapKey(ctx context.Context, cm *v1.ConfigMap, nodeID, text string) (string, bool, error) {
+	config, err := parseConfigMapKey(cm, nodeID, text)
+	if err != nil {
+		return nodeID, false, err
+	}
 
-			if !ok {
-				return errAssertion
-			}
+	applied, err := saveConfig(ctx, config, cm.Labels)
 
-			cs.Stop()
+	return config.ID, applied, err
+}
+
+func parseConfigMapKey(cm *v1.ConfigMap, nodeID, text string) (*appConfig.ConfigType, error) {
+	// per node configs are rendered when envoy connects, default config has empty node
+	config, err := appConfig.ParseConfigMapYaml(cm.Namespace, nodeID, text, appConfig.NewTemplateData(nil))
+	if err != nil {
+		return nil, err
+	}
+
+	if len(config.ID) == 0 {
+		config.ID = nodeID
+	}
+
+	config.ConfigSourceKind = appConfig.ConfigSourceConfigMap
+	config.ConfigMapName = cm.Name
+	config.ConfigMapNamespace = cm.Namespace
+	config.ConfigMapAnnotations = cm.Annotations
+	config.ConfigMapResourceVersion = cm.ResourceVersion
+
+	return config, nil
+}
+
+// create new configStore for config, sourceLabels used for version label.
+// running configStore is replaced only when new config is valid, returns true if new config was applied.
+func saveConfig(ctx context.Context, config *appConfig.ConfigType, sourceLabels map[string]string) (bool, error) {
+	if err := prepareConfig(config, sourceLabels); err != nil {
+		return false, err
+	}
+
+	configHash, err := configstore.GetConfigHash(config)
+	if err != nil {
+		return false, errors.Wrap(err, "error in configstore.GetConfigHash")
+	}
+
+	var currentConfigStore *configstore.ConfigStore
+
+	if v, ok := configstore.StoreMap.Load(config.ID); ok {
+		cs, ok := v.(*configstore.ConfigStore)
+
+		if !ok {
+			return false, errAssertion
 		}
 
-		if err := config.SaveResources(); err != nil {
-			return errors.Wrap(err, "error in config.SaveResources")
+		// generated resources are the same, nothing to push
+		if cs.ConfigHash == configHash {
+			log.Infof("configStore %s not changed, hash=%s", config.ID, configHash)
+
+			cs.SetConfigMapAnnotations(config.ConfigMapAnnotations)
+
+			return false, nil
 		}
 
-		log.Infof("Create configStore %s", config.ID)
+		currentConfigStore = cs
+	}
 
-		newConfigStore, err := configstore.New(ctx, config)
-		if err != nil {
-			return err
+	log.Infof("Create configStore %s", config.ID)
+
+	newConfigStore, err := configstore.New(config)
+	if err != nil {
+		return false, errors.Wrap(err, "error in configstore.New")
+	}
+
+	if currentConfigStore != nil {
+		currentConfigStore.Stop()
+	}
+
+	configstore.StoreMap.Store(config.ID, newConfigStore)
+
+	newConfigStore.Start(ctx)
+
+	newConfigStore.Event(v1.EventTypeNormal, api.EventReasonConfigApplied, "node %s config applied, hash=%s", config.ID, configHash) //nolint:lll
+
+	return true, nil
+}
+
+// parse resources of config, sourceLabels used for version label.
+func prepareConfig(config *appConfig.ConfigType, sourceLabels map[string]string) error {
+	if len(config.Name) == 0 {
+		config.Name = config.ID
+	}
+
+	if config.UseVersionLabel && len(sourceLabels[config.VersionLabelKey]) > 0 {
+		log.Debug("update Id, using UseVersionLabel")
+
+		config.VersionLabel = sourceLabels[config.VersionLabelKey]
+		config.ID = fmt.Sprintf("%s-%s", config.ID, config.VersionLabel)
+	}
+
+	for i := 0; i < len(config.Kubernetes); i++ {
+		if len(config.Kubernetes[i].Namespace) == 0 {
+			log.Debug("namespace not set - using configmap namespace")
+
+			config.Kubernetes[i].Namespace = config.ConfigMapNamespace
 		}
+	}
 
-		configstore.StoreMap.Store(config.ID, newConfigStore)
+	if err := config.SaveResources(); err != nil {
+		return errors.Wrap(err, "error in config.SaveResources")
 	}
 
 	return nil
 }
 
-func DeleteConfigMap(cm *v1.ConfigMap) {
+func DeleteConfigMap(ctx context.Context, cm *v1.ConfigMap) {
+	if isFragmentsConfigMap(cm) {
+		mutex.Lock()
+		defer mutex.Unlock()
+
+		deleteFragments(ctx, cm)
+
+		return
+	}
+
+	deleteConfigSource(appConfig.ConfigSourceConfigMap, cm.Namespace, cm.Name)
+}
+
+func deleteConfigSource(kind, namespace, name string) {
+	configstore.DeleteConfigSourceErrors(kind + "/" + namespace + "/" + name)
+
 	configstore.StoreMap.Range(func(key interface{}, value interface{}) bool {
 		cs, ok := value.(*configstore.ConfigStore)
 
 		if !ok {
-			log.WithError(errAssertion).Fatal("DeleteConfigMap v.(*ConfigStore)")
+			log.WithError(errAssertion).Fatal("deleteConfigSource v.(*ConfigStore)")
 		}
 
-		if cs.Config.ConfigMapName == cm.Name && cs.Config.ConfigMapNamespace == cm.Namespace {
+		if cs.Config.ConfigSourceKind == kind && cs.Config.ConfigMapName == name && cs.Config.ConfigMapNamespace == namespace { //nolint:lll
 			cs.Stop()
 
-			time.Sleep(*config.Get().ConfigDrainPeriod)
+			time.Sleep(*appConfig.Get().ConfigDrainPeriod)
 
 			controlplane.SnapshotCache.ClearSnapshot(cs.Config.ID)
+			cs.ClearNodeSnapshots(context.Background())
+
+			snapshotstore.DeleteAsync(context.Background(), cs.Config.ID)
+
+			configstore.DeleteConfigError(cs.Config.ID)
+			configstore.DeleteHistory(cs.Config.ID)
 			configstore.StoreMap.Delete(key)
+			controlplane.UnregisterConfigID(cs.Config.ID)
+		}
+
+		return true
+	})
+}
+
+// find configStore of config source.
+func getConfigSourceStore(kind, namespace, name string) *configstore.ConfigStore {
+	if stores := getConfigSourceStores(kind, namespace, name); len(stores) > 0 {
+		return stores[0]
+	}
+
+	return nil
+}
+
+// find all configStores of config source, ConfigMap can have many nodes.
+func getConfigSourceStores(kind, namespace, name string) []*configstore.ConfigStore {
+	result := make([]*configstore.ConfigStore, 0)
+
+	configstore.StoreMap.Range(func(_ interface{}, value interface{}) bool {
+		cs, ok := value.(*configstore.ConfigStore)
+
+		if !ok {
+			log.WithError(errAssertion).Fatal("getConfigSourceStores v.(*ConfigStore)")
+		}
+
+		if cs.Config.ConfigSourceKind == kind && cs.Config.ConfigMapName == name && cs.Config.ConfigMapNamespace == namespace { //nolint:lll
+			result = append(result, cs)
 		}
 
 		return true
 	})
+
+	return result
 }
@@ -0,0 +1,98 @@
+/*
+Copyright paskal.maksim@gmail.com
+Licensed under the Apache License, Version 2.0 (the "License")
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package configmapsstore
+
+import (
+	"sort"
+
+	"github.com/maksim-paskal/envoy-control-plane/pkg/configstore"
+	"github.com/maksim-paskal/envoy-control-plane/pkg/controlplane"
+	"github.com/maksim-paskal/envoy-control-plane/pkg/utils"
+	"github.com/pkg/errors"
+	v1 "k8s.io/api/core/v1"
+)
+
+// changes that ConfigMap key will push to envoy.
+type DryRunResult struct {
+	Key     string
+	NodeID  string
+	Error   string                `json:",omitempty"`
+	Changes []*utils.ResourceDiff `json:",omitempty"`
+}
+
+// diff of ConfigMap configs with snapshots in SnapshotCache, nothing is applied.
+func DryRunConfigMap(cm *v1.ConfigMap) ([]*DryRunResult, error) {
+	if isFragmentsConfigMap(cm) {
+		return nil, errDryRunFragments
+	}
+
+	keys := make([]string, 0, len(cm.Data))
+
+	for key := range cm.Data {
+		keys = append(keys, key)
+	}
+
+	sort.Strings(keys)
+
+	results := make([]*DryRunResult, 0, len(keys))
+
+	for _, key := range keys {
+		result := &DryRunResult{Key: key, NodeID: key}
+
+		nodeID, changes, err := dryRunConfigMapKey(cm, key, cm.Data[key])
+		if len(nodeID) > 0 {
+			result.NodeID = nodeID
+		}
+
+		if err != nil {
+			result.Error = err.Error()
+		}
+
+		result.Changes = changes
+
+		results = append(results, result)
+	}
+
+	return results, nil
+}
+
+func dryRunConfigMapKey(cm *v1.ConfigMap, nodeID, text string) (string, []*utils.ResourceDiff, error) {
+	config, err := parseConfigMapKey(cm, nodeID, text)
+	if err != nil {
+		return "", nil, err
+	}
+
+	if err := prepareConfig(config, cm.Labels); err != nil {
+		return config.ID, nil, err
+	}
+
+	cs, err := configstore.New(config)
+	if err != nil {
+		return config.ID, nil, errors.Wrap(err, "error in configstore.New")
+	}
+
+	candidate, err := cs.DryRun()
+	if err != nil {
+		return config.ID, nil, err
+	}
+
+	// node without snapshot will receive all resources
+	current, _ := controlplane.SnapshotCache.GetSnapshot(config.ID)
+
+	changes, err := utils.DiffSnapshots(current, candidate)
+	if err != nil {
+		return config.ID, nil, errors.Wrap(err, "error in DiffSnapshots")
+	}
+
+	return config.ID, changes, nil
+}
@@ -0,0 +1,79 @@
+/*
+Copyright paskal.maksim@gmail.com
+Licensed under the Apache License, Version 2.0 (the "License")
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package configmapsstore_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/maksim-paskal/envoy-control-plane/pkg/certs"
+	"github.com/maksim-paskal/envoy-control-plane/pkg/config"
+	"github.com/maksim-paskal/envoy-control-plane/pkg/configmapsstore"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const dryRunConfig = `
+clusters:
+- name: local_service
+  connect_timeout: 1s
+  type: STATIC
+  load_assignment:
+    cluster_name: local_service
+    endpoints:
+    - lb_endpoints:
+      - endpoint:
+          address:
+            socket_address:
+              address: 127.0.0.1
+              port_value: 8000
+`
+
+func TestDryRunUnchangedConfigMap(t *testing.T) {
+	t.Parallel()
+
+	if err := config.Load(); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := certs.Init(); err != nil {
+		t.Fatal(err)
+	}
+
+	cm := &v1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "dry-run-test",
+			Namespace: "default",
+		},
+		Data: map[string]string{
+			"dry-run-test-id": dryRunConfig,
+		},
+	}
+
+	if err := configmapsstore.ApplyConfigMapKey(context.Background(), cm, "dry-run-test-id"); err != nil {
+		t.Fatal(err)
+	}
+
+	results, err := configmapsstore.DryRunConfigMap(cm)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(results) != 1 || len(results[0].Error) > 0 {
+		t.Fatalf("not correct results %+v", results)
+	}
+
+	if changes := results[0].Changes; len(changes) > 0 {
+		t.Fatalf("unchanged ConfigMap must have no changes %+v", changes[0])
+	}
+}
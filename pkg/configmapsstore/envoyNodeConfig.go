@@ -0,0 +1,137 @@
+/*
+Copyright paskal.maksim@gmail.com
+Licensed under the Apache License, Version 2.0 (the "License")
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package configmapsstore
+
+import (
+	"context"
+	"sync"
+
+	"github.com/maksim-paskal/envoy-control-plane/pkg/api"
+	appConfig "github.com/maksim-paskal/envoy-control-plane/pkg/config"
+	"github.com/maksim-paskal/envoy-control-plane/pkg/controlplane"
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+	"gopkg.in/yaml.v3"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// last reconciliation errors of EnvoyNodeConfig by namespace/name.
+var envoyNodeConfigErrors = new(sync.Map)
+
+func NewEnvoyNodeConfig(ctx context.Context, enc *unstructured.Unstructured) error {
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	return applyEnvoyNodeConfig(ctx, enc)
+}
+
+func applyEnvoyNodeConfig(ctx context.Context, enc *unstructured.Unstructured) error {
+	err := newEnvoyNodeConfig(ctx, enc)
+	if err != nil {
+		envoyNodeConfigErrors.Store(enc.GetNamespace()+"/"+enc.GetName(), []string{err.Error()})
+
+		api.Event(enc, v1.EventTypeWarning, api.EventReasonConfigRejected, err.Error())
+	} else {
+		envoyNodeConfigErrors.Delete(enc.GetNamespace() + "/" + enc.GetName())
+	}
+
+	if err := updateEnvoyNodeConfigStatus(ctx, enc); err != nil {
+		log.WithError(err).Error("error updating EnvoyNodeConfig status")
+	}
+
+	return err
+}
+
+func newEnvoyNodeConfig(ctx context.Context, enc *unstructured.Unstructured) error {
+	spec, _, err := unstructured.NestedMap(enc.Object, "spec")
+	if err != nil {
+		return errors.Wrap(err, "error getting spec")
+	}
+
+	specYaml, err := yaml.Marshal(spec)
+	if err != nil {
+		return errors.Wrap(err, "yaml.Marshal")
+	}
+
+	config, err := appConfig.NewConfigFromYaml(specYaml)
+	if err != nil {
+		return err
+	}
+
+	if err := config.ApplyExtends(enc.GetNamespace(), appConfig.NewTemplateData(nil)); err != nil {
+		return errors.Wrap(err, "error in extends")
+	}
+
+	if len(config.ID) == 0 {
+		config.ID = enc.GetName()
+	}
+
+	config.ConfigSourceKind = appConfig.ConfigSourceEnvoyNodeConfig
+	config.ConfigMapName = enc.GetName()
+	config.ConfigMapNamespace = enc.GetNamespace()
+	config.ConfigMapAnnotations = enc.GetAnnotations()
+	config.ConfigMapResourceVersion = enc.GetResourceVersion()
+
+	_, err = saveConfig(ctx, config, enc.GetLabels())
+
+	return err
+}
+
+func DeleteEnvoyNodeConfig(enc *unstructured.Unstructured) {
+	envoyNodeConfigErrors.Delete(enc.GetNamespace() + "/" + enc.GetName())
+
+	deleteConfigSource(appConfig.ConfigSourceEnvoyNodeConfig, enc.GetNamespace(), enc.GetName())
+}
+
+// write last pushed version and connected nodes to all EnvoyNodeConfig.
+func SyncEnvoyNodeConfigStatus(ctx context.Context) {
+	if !api.IsLeader() {
+		return
+	}
+
+	envoyNodeConfigs, err := api.ListEnvoyNodeConfigs()
+	if err != nil {
+		log.WithError(err).Error("error listing EnvoyNodeConfig")
+
+		return
+	}
+
+	for _, enc := range envoyNodeConfigs {
+		if err := updateEnvoyNodeConfigStatus(ctx, enc); err != nil {
+			log.WithError(err).Error("error updating EnvoyNodeConfig status")
+		}
+	}
+}
+
+func updateEnvoyNodeConfigStatus(ctx context.Context, enc *unstructured.Unstructured) error {
+	status := &api.EnvoyNodeConfigStatus{
+		ObservedGeneration: enc.GetGeneration(),
+	}
+
+	if v, ok := envoyNodeConfigErrors.Load(enc.GetNamespace() + "/" + enc.GetName()); ok {
+		parseErrors, ok := v.([]string)
+		if !ok {
+			return errAssertion
+		}
+
+		status.ParseErrors = parseErrors
+	}
+
+	if cs := getConfigSourceStore(appConfig.ConfigSourceEnvoyNodeConfig, enc.GetNamespace(), enc.GetName()); cs != nil {
+		status.LastPushedVersion = cs.GetVersion()
+		status.ConnectedNodes = int64(controlplane.GetConnectedNodes(cs.Config.ID))
+	}
+
+	return api.UpdateEnvoyNodeConfigStatus(ctx, enc.GetNamespace(), enc.GetName(), status)
+}
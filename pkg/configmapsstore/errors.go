@@ -14,4 +14,7 @@ package configmapsstore
 
 import "errors"
 
-var errAssertion = errors.New("assertion error")
+var (
+	errAssertion       = errors.New("assertion error")
+	errDryRunFragments = errors.New("fragments ConfigMap can not be used in dry run")
+)
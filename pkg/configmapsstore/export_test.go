@@ -0,0 +1,51 @@
+/*
+Copyright paskal.maksim@gmail.com
+Licensed under the Apache License, Version 2.0 (the "License")
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package configmapsstore
+
+import (
+	"context"
+
+	"github.com/maksim-paskal/envoy-control-plane/pkg/configstore"
+	"github.com/maksim-paskal/envoy-control-plane/pkg/controlplane"
+	v1 "k8s.io/api/core/v1"
+)
+
+// serve config of ConfigMap key without kubernetes endpoints.
+func ApplyConfigMapKey(ctx context.Context, cm *v1.ConfigMap, key string) error {
+	config, err := parseConfigMapKey(cm, key, cm.Data[key])
+	if err != nil {
+		return err
+	}
+
+	if err := prepareConfig(config, cm.Labels); err != nil {
+		return err
+	}
+
+	cs, err := configstore.New(config)
+	if err != nil {
+		return err
+	}
+
+	snap, err := cs.DryRun()
+	if err != nil {
+		return err
+	}
+
+	if err := controlplane.SnapshotCache.SetSnapshot(ctx, config.ID, snap); err != nil {
+		return err
+	}
+
+	configstore.StoreMap.Store(config.ID, cs)
+
+	return nil
+}
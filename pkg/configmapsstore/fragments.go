@@ -0,0 +1,115 @@
+/*
+Copyright paskal.maksim@gmail.com
+Licensed under the Apache License, Version 2.0 (the "License")
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package configmapsstore
+
+import (
+	"context"
+
+	"github.com/maksim-paskal/envoy-control-plane/pkg/api"
+	appConfig "github.com/maksim-paskal/envoy-control-plane/pkg/config"
+	"github.com/maksim-paskal/envoy-control-plane/pkg/configstore"
+	log "github.com/sirupsen/logrus"
+	v1 "k8s.io/api/core/v1"
+)
+
+// ConfigMap with shared fragments, keys of this ConfigMap are not node configs.
+func isFragmentsConfigMap(cm *v1.ConfigMap) bool {
+	return cm.Annotations[appConfig.AnnotationFragments] == "true"
+}
+
+func applyFragments(ctx context.Context, cm *v1.ConfigMap) {
+	log.Infof("load fragments from ConfigMap %s/%s", cm.Namespace, cm.Name)
+
+	appConfig.SetFragments(cm.Namespace, cm.Name, cm.Data)
+
+	applyFragmentDependents(ctx, cm.Namespace, cm.Name)
+}
+
+func deleteFragments(ctx context.Context, cm *v1.ConfigMap) {
+	log.Infof("delete fragments from ConfigMap %s/%s", cm.Namespace, cm.Name)
+
+	appConfig.DeleteFragments(cm.Namespace, cm.Name)
+
+	applyFragmentDependents(ctx, cm.Namespace, cm.Name)
+}
+
+// render again configs that uses fragments of ConfigMap,
+// rejected configs are also rendered, they can wait for this fragments.
+func applyFragmentDependents(ctx context.Context, namespace, name string) {
+	configMaps, err := api.ListConfigMaps()
+	if err != nil {
+		log.WithError(err).Error("error listing ConfigMaps")
+
+		return
+	}
+
+	for _, cm := range configMaps {
+		if isFragmentsConfigMap(cm) || !checkConfigMapLabels(cm) {
+			continue
+		}
+
+		if !usesFragments(appConfig.ConfigSourceConfigMap, cm.Namespace, cm.Name, namespace, name) {
+			continue
+		}
+
+		log.Infof("fragments %s/%s changed, render ConfigMap %s/%s", namespace, name, cm.Namespace, cm.Name)
+
+		if err := newConfigMap(ctx, cm); err != nil {
+			log.WithError(err).Error()
+		}
+	}
+
+	envoyNodeConfigs, err := api.ListEnvoyNodeConfigs()
+	if err != nil {
+		log.WithError(err).Error("error listing EnvoyNodeConfig")
+
+		return
+	}
+
+	for _, enc := range envoyNodeConfigs {
+		if !usesFragments(appConfig.ConfigSourceEnvoyNodeConfig, enc.GetNamespace(), enc.GetName(), namespace, name) {
+			continue
+		}
+
+		log.Infof("fragments %s/%s changed, render EnvoyNodeConfig %s/%s", namespace, name, enc.GetNamespace(), enc.GetName()) //nolint:lll
+
+		if err := applyEnvoyNodeConfig(ctx, enc); err != nil {
+			log.WithError(err).Error()
+		}
+	}
+}
+
+// returns true if config source uses fragments of ConfigMap or has rejected configs.
+func usesFragments(kind, sourceNamespace, sourceName, namespace, name string) bool {
+	source := kind + "/" + sourceNamespace + "/" + sourceName
+
+	for _, configError := range configstore.GetConfigErrors() {
+		if configError.Source == source {
+			return true
+		}
+	}
+
+	if kind == appConfig.ConfigSourceEnvoyNodeConfig {
+		if _, ok := envoyNodeConfigErrors.Load(sourceNamespace + "/" + sourceName); ok {
+			return true
+		}
+	}
+
+	for _, cs := range getConfigSourceStores(kind, sourceNamespace, sourceName) {
+		if cs.Config.UsesFragments(namespace, name) {
+			return true
+		}
+	}
+
+	return false
+}
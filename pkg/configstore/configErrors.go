@@ -0,0 +1,84 @@
+/*
+Copyright paskal.maksim@gmail.com
+Licensed under the Apache License, Version 2.0 (the "License")
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package configstore
+
+import (
+	"sync"
+	"time"
+
+	"github.com/maksim-paskal/envoy-control-plane/pkg/metrics"
+)
+
+type ConfigError struct {
+	Source string
+	Error  string
+	Time   time.Time
+}
+
+// last rejected config update by nodeID.
+var configErrors = new(sync.Map)
+
+// save rejected config update, last good config is still served.
+func SetConfigError(nodeID, source string, err error) {
+	configErrors.Store(nodeID, &ConfigError{
+		Source: source,
+		Error:  err.Error(),
+		Time:   time.Now(),
+	})
+
+	metrics.ConfigmapsstoreRejected.WithLabelValues(nodeID).Inc()
+	metrics.ConfigmapsstoreLastRejected.WithLabelValues(nodeID).Set(1)
+}
+
+func ClearConfigError(nodeID string) {
+	configErrors.Delete(nodeID)
+
+	metrics.ConfigmapsstoreLastRejected.WithLabelValues(nodeID).Set(0)
+}
+
+func DeleteConfigError(nodeID string) {
+	configErrors.Delete(nodeID)
+
+	metrics.ConfigmapsstoreLastRejected.DeleteLabelValues(nodeID)
+}
+
+// delete all rejected config updates of config source.
+func DeleteConfigSourceErrors(source string) {
+	for nodeID, configError := range GetConfigErrors() {
+		if configError.Source == source {
+			DeleteConfigError(nodeID)
+		}
+	}
+}
+
+func GetConfigErrors() map[string]*ConfigError {
+	result := make(map[string]*ConfigError)
+
+	configErrors.Range(func(key, value interface{}) bool {
+		nodeID, ok := key.(string)
+		if !ok {
+			return true
+		}
+
+		configError, ok := value.(*ConfigError)
+		if !ok {
+			return true
+		}
+
+		result[nodeID] = configError
+
+		return true
+	})
+
+	return result
+}
@@ -17,7 +17,10 @@ import (
 	"fmt"
 	"net"
 	"reflect"
+	"slices"
 	"sort"
+	"strconv"
+	"strings"
 	"sync"
 	"time"
 
@@ -25,49 +28,64 @@ import (
 	endpoint "github.com/envoyproxy/go-control-plane/envoy/config/endpoint/v3"
 	tls "github.com/envoyproxy/go-control-plane/envoy/extensions/transport_sockets/tls/v3"
 	"github.com/envoyproxy/go-control-plane/pkg/cache/types"
+	"github.com/envoyproxy/go-control-plane/pkg/cache/v3"
 	"github.com/envoyproxy/go-control-plane/pkg/resource/v3"
-	"github.com/google/uuid"
 	"github.com/maksim-paskal/envoy-control-plane/pkg/api"
 	appConfig "github.com/maksim-paskal/envoy-control-plane/pkg/config"
 	"github.com/maksim-paskal/envoy-control-plane/pkg/controlplane"
 	"github.com/maksim-paskal/envoy-control-plane/pkg/metrics"
 	"github.com/maksim-paskal/envoy-control-plane/pkg/resources"
+	"github.com/maksim-paskal/envoy-control-plane/pkg/snapshotstore"
 	"github.com/maksim-paskal/envoy-control-plane/pkg/utils"
 	"github.com/pkg/errors"
 	log "github.com/sirupsen/logrus"
 	"go.uber.org/atomic"
+	"google.golang.org/protobuf/proto"
 	"google.golang.org/protobuf/types/known/structpb"
-	"gopkg.in/yaml.v3"
 	corev1 "k8s.io/api/core/v1"
+	discoveryv1 "k8s.io/api/discovery/v1"
 )
 
 var StoreMap = new(sync.Map)
 
 const (
-	defaultZone         = "unknown"
-	envoyMetaPodName    = "k8s.pod.name"
-	envoyMetaPodLabels  = "k8s.pod.labels."
-	envoyMetaEndpointIP = "k8s.endpoint.ip"
-	envoyMetaNodeName   = "k8s.node.name"
-	podLabelIgnore      = "pod-template-hash"
+	defaultZone          = "unknown"
+	envoyMetaPodName     = "k8s.pod.name"
+	envoyMetaPodLabels   = "k8s.pod.labels."
+	envoyMetaEndpointIP  = "k8s.endpoint.ip"
+	envoyMetaNodeName    = "k8s.node.name"
+	envoyMetaClusterName = "k8s.cluster.name"
+	envoyMetaZone        = "k8s.endpoint.zone"
+	envoyMetaHintsZones  = "k8s.endpoint.hints.zones"
+	envoyMetaReady       = "k8s.endpoint.ready"
+	envoyMetaServing     = "k8s.endpoint.serving"
+	envoyMetaTerminating = "k8s.endpoint.terminating"
+	podLabelIgnore       = "pod-template-hash"
 )
 
 type ConfigStore struct {
-	Version            string
-	Config             *appConfig.ConfigType
-	configEndpoints    map[string][]*endpoint.LocalityLbEndpoints
-	lastEndpoints      []types.Resource
-	lastEndpointsArray []string
-	log                *log.Entry
-	mutex              sync.Mutex
-	secrets            []tls.Secret
-	isStoped           *atomic.Bool
-}
-
-func New(ctx context.Context, config *appConfig.ConfigType) (*ConfigStore, error) {
+	Version string
+	// hash of parsed config, store with same hash will produce same resources
+	ConfigHash           string
+	Config               *appConfig.ConfigType
+	snapshot             *cache.Snapshot
+	configEndpoints      map[string][]*endpoint.LocalityLbEndpoints
+	lastEndpoints        []types.Resource
+	lastEndpointsArray   []string
+	lastEndpointsVersion string
+	log                  *log.Entry
+	mutex                sync.Mutex
+	secrets              []tls.Secret
+	isStoped             *atomic.Bool
+	// rendered configs of connected envoys by node hash
+	nodeConfigs map[string]*nodeConfig
+}
+
+func New(config *appConfig.ConfigType) (*ConfigStore, error) {
 	cs := ConfigStore{
-		Config:   config,
-		isStoped: atomic.NewBool(false),
+		Config:      config,
+		isStoped:    atomic.NewBool(false),
+		nodeConfigs: make(map[string]*nodeConfig),
 		log: log.WithFields(log.Fields{
 			"type":   "ConfigStore",
 			"nodeID": config.ID,
@@ -75,7 +93,7 @@ func New(ctx context.Context, config *appConfig.ConfigType) (*ConfigStore, error
 	}
 
 	if log.GetLevel() >= log.DebugLevel {
-		obj, err := yaml.Marshal(config)
+		obj, err := utils.RedactYAML(config)
 		if err != nil {
 			cs.log.WithError(err).Error()
 		}
@@ -85,20 +103,104 @@ func New(ctx context.Context, config *appConfig.ConfigType) (*ConfigStore, error
 
 	var err error
 
+	cs.ConfigHash, err = GetConfigHash(config)
+	if err != nil {
+		return nil, errors.Wrap(err, "error in GetConfigHash")
+	}
+
+	if err = checkKubernetesClusters(config); err != nil {
+		return nil, err
+	}
+
 	cs.configEndpoints, err = cs.getConfigEndpoints()
 	if err != nil {
-		cs.log.WithError(err).Error()
+		return nil, errors.Wrap(err, "error in getConfigEndpoints")
 	}
 
-	if err = cs.LoadNewSecrets(); err != nil {
-		return nil, errors.Wrap(err, "error in LoadNewSecrets")
+	// new secrets will push SDS on every config change
+	if !cs.setSecretsFromRunning() {
+		if err = cs.LoadNewSecrets(); err != nil {
+			return nil, errors.Wrap(err, "error in LoadNewSecrets")
+		}
 	}
 
-	cs.saveLastEndpoints(ctx)
+	// build snapshot before store will be used, invalid config must not replace running config
+	snap, err := utils.GetHashedConfigSnapshot(cs.Config, nil, cs.secrets)
+	if err != nil {
+		return nil, errors.Wrap(err, "error in GetHashedConfigSnapshot")
+	}
+
+	if err = utils.ValidateSnapshot(snap); err != nil {
+		return nil, errors.Wrap(err, "error in ValidateSnapshot")
+	}
 
 	return &cs, nil
 }
 
+// start pushing snapshots of config store.
+func (cs *ConfigStore) Start(ctx context.Context) {
+	controlplane.RegisterConfigID(cs.Config.ID)
+	controlplane.SetPerNode(cs.Config.ID, cs.isPerNode())
+	ReleaseRollbacks(cs.Config.ID)
+
+	// render configs for already connected envoys
+	if cs.isPerNode() {
+		for _, node := range controlplane.GetNodes(cs.Config.ID) {
+			if err := cs.addNode(node); err != nil {
+				cs.log.WithError(err).Error("error rendering node config")
+			}
+		}
+	}
+
+	cs.saveLastEndpoints(ctx)
+}
+
+// hash of config resources, secrets generated by control-plane are not included.
+func GetConfigHash(config *appConfig.ConfigType) (string, error) {
+	resourcesVersions := make([]string, 0)
+
+	for _, items := range [][]types.Resource{
+		config.GetClusters(),
+		config.GetRoutes(),
+		config.GetListeners(),
+		config.GetSecrets(),
+	} {
+		version, err := utils.GetResourcesVersion(items)
+		if err != nil {
+			return "", err
+		}
+
+		resourcesVersions = append(resourcesVersions, version)
+	}
+
+	return utils.GetJSONHash(
+		config.ID,
+		config.Name,
+		config.PerNode,
+		config.ConfigTemplate,
+		config.Fragments,
+		config.Kubernetes,
+		config.Endpoints,
+		config.Validation,
+		resourcesVersions,
+	)
+}
+
+// all kubernetes clusters in config must be known.
+func checkKubernetesClusters(config *appConfig.ConfigType) error {
+	clusterNames := api.GetClusterNames()
+
+	for _, kubernetes := range config.Kubernetes {
+		for _, cluster := range kubernetes.Clusters {
+			if !slices.Contains(clusterNames, cluster.Name) {
+				return errors.Wrapf(errUnknownCluster, "cluster_name=%s,cluster=%s", kubernetes.ClusterName, cluster.Name)
+			}
+		}
+	}
+
+	return nil
+}
+
 func (cs *ConfigStore) hasStoped() bool {
 	return cs.isStoped.Load()
 }
@@ -111,7 +213,7 @@ func (cs *ConfigStore) NewPod(ctx context.Context, _ *corev1.Pod) {
 	cs.saveLastEndpoints(ctx)
 }
 
-func (cs *ConfigStore) NewEndpoint(ctx context.Context, _ *corev1.Endpoints) {
+func (cs *ConfigStore) NewEndpoint(ctx context.Context, _ *discoveryv1.EndpointSlice) {
 	cs.NewPod(ctx, nil)
 }
 
@@ -125,30 +227,94 @@ func (cs *ConfigStore) Push(ctx context.Context, reason string) {
 
 	metrics.ConfigmapsstorePush.Inc()
 
-	for {
-		newVersion := uuid.New().String()
-		if newVersion != cs.Version {
-			cs.Version = newVersion
+	// every resource type has own version, only changed types will be sent to envoy
+	snap, err := utils.GetHashedConfigSnapshot(cs.Config, cs.lastEndpoints, cs.secrets)
+	if err != nil {
+		cs.log.WithError(err).Error()
+
+		return
+	}
+
+	cs.snapshot = snap
+	cs.Version = utils.GetSnapshotVersion(snap)
+
+	cs.setSnapshot(ctx, cs.Config.ID, snap, reason)
+
+	for nodeHash, nodeConfig := range cs.nodeConfigs {
+		nodeSnap, err := utils.GetHashedConfigSnapshot(nodeConfig.config, cs.lastEndpoints, cs.secrets)
+		if err != nil {
+			cs.log.WithError(err).Error()
 
-			break
+			continue
 		}
+
+		cs.setSnapshot(ctx, nodeHash, nodeSnap, reason)
 	}
+}
 
-	snap, err := utils.GetConfigSnapshot(cs.Version, cs.Config, cs.lastEndpoints, cs.secrets)
-	if err != nil {
-		cs.log.WithError(err).Error()
+// set snapshot in cache and snapshot store, if all resources hashes are the same - nothing to push.
+func (cs *ConfigStore) setSnapshot(ctx context.Context, nodeHash string, snap *cache.Snapshot, reason string) {
+	version := utils.GetSnapshotVersion(snap)
 
-		return
+	if pinned, rollback := cs.addHistory(nodeHash, snap, reason); len(pinned) > 0 {
+		if !rollback {
+			cs.log.Warnf("node %s pinned to version %s, skip push %s, reason=%s", nodeHash, pinned, version, reason)
+
+			return
+		}
+
+		// rolled back node serves config of acknowledged version with live endpoints
+		rollbackSnap, err := getRollbackSnapshot(nodeHash, pinned, snap)
+		if err != nil {
+			cs.log.WithError(err).Errorf("error in rollback snapshot %s", nodeHash)
+
+			return
+		}
+
+		cs.log.Warnf("node %s rolled back to version %s, push endpoints of %s, reason=%s", nodeHash, pinned, version, reason)
+
+		snap = rollbackSnap
+		version = utils.GetSnapshotVersion(snap)
 	}
 
-	err = controlplane.SnapshotCache.SetSnapshot(ctx, cs.Config.ID, snap)
-	if err != nil {
+	if current, err := controlplane.SnapshotCache.GetSnapshot(nodeHash); err == nil {
+		if utils.GetSnapshotVersion(current) == version {
+			cs.log.Debugf("no changes in resources, skip push %s, reason=%s", nodeHash, reason)
+
+			return
+		}
+	}
+
+	if err := controlplane.SnapshotCache.SetSnapshot(ctx, nodeHash, snap); err != nil {
 		cs.log.WithError(err).Error()
 
 		return
 	}
 
-	cs.log.WithField("version", cs.Version).Infof("pushed, reason=%s", reason)
+	// saving must not delay response to envoy
+	snapshotstore.SaveAsync(ctx, nodeHash, snap)
+
+	if nodeHash == cs.Config.ID {
+		cs.moveStaleNodes(ctx, snap)
+	}
+
+	cs.log.WithField("version", version).Infof("pushed %s, reason=%s", nodeHash, reason)
+}
+
+// respond to envoys that waits snapshot with own node id,
+// next request of this envoys will use config id.
+func (cs *ConfigStore) moveStaleNodes(ctx context.Context, snap *cache.Snapshot) {
+	for _, nodeKey := range controlplane.GetStaleNodeKeys(cs.Config.ID) {
+		if err := controlplane.SnapshotCache.SetSnapshot(ctx, nodeKey, snap); err != nil {
+			cs.log.WithError(err).Errorf("error moving %s", nodeKey)
+
+			continue
+		}
+
+		controlplane.SnapshotCache.ClearSnapshot(nodeKey)
+
+		cs.log.Infof("node %s moved to %s", nodeKey, cs.Config.ID)
+	}
 }
 
 func (cs *ConfigStore) getConfigEndpoints() (map[string][]*endpoint.LocalityLbEndpoints, error) {
@@ -186,8 +352,38 @@ func (cs *ConfigStore) LoadNewSecrets() error {
 	return nil
 }
 
-func (cs *ConfigStore) getEndpointLocality(node string) *core.Locality {
-	nodeInfo, err := api.GetNode(node)
+// use secrets of running store with the same secret names and validation, returns true if secrets were reused.
+func (cs *ConfigStore) setSecretsFromRunning() bool {
+	value, ok := StoreMap.Load(cs.Config.ID)
+	if !ok {
+		return false
+	}
+
+	running, ok := value.(*ConfigStore)
+	if !ok {
+		return false
+	}
+
+	if running.Config.Name != cs.Config.Name || !reflect.DeepEqual(running.Config.Validation, cs.Config.Validation) {
+		return false
+	}
+
+	running.mutex.Lock()
+	secrets := running.secrets
+	running.mutex.Unlock()
+
+	cs.mutex.Lock()
+	defer cs.mutex.Unlock()
+
+	cs.secrets = secrets
+
+	cs.log.Debug("using secrets of running store")
+
+	return true
+}
+
+func (cs *ConfigStore) getEndpointLocality(cluster, node string) *core.Locality {
+	nodeInfo, err := api.GetClusterNode(cluster, node)
 	if err != nil {
 		log.WithError(err).Errorf("can not get node info for %s", node)
 
@@ -208,26 +404,62 @@ func (cs *ConfigStore) getEndpointLocality(node string) *core.Locality {
 }
 
 type envoyEndpoint struct {
-	IsCanary bool
-	Node     string
-	Address  string
-	Item     appConfig.KubernetesType
-	Metadata map[string]string
+	IsCanary     bool
+	Cluster      appConfig.KubernetesClusterType
+	Node         string
+	Zone         string
+	Address      string
+	Item         appConfig.KubernetesType
+	Metadata     map[string]string
+	HealthStatus core.HealthStatus
 }
 
-func (e *envoyEndpoint) SetNode(address corev1.EndpointAddress) {
-	if address.NodeName != nil {
-		e.Node = *address.NodeName
+func (e *envoyEndpoint) SetNode(ep discoveryv1.Endpoint) {
+	if ep.NodeName != nil {
+		e.Node = *ep.NodeName
+	}
+
+	// zone from EndpointSlice has priority over node labels
+	if ep.Zone != nil {
+		e.Zone = *ep.Zone
 	}
 }
 
-func (cs *ConfigStore) getEnvoyLocalityLbEndpoint(envoyEndpoint *envoyEndpoint) *endpoint.LocalityLbEndpoints { //nolint:lll
-	priority := uint32(0)
+func (e *envoyEndpoint) GetLocality(cs *ConfigStore) *core.Locality {
+	locality := &core.Locality{
+		Zone: e.Zone,
+	}
+
+	if len(e.Zone) == 0 {
+		locality = cs.getEndpointLocality(e.Cluster.Name, e.Node)
+	}
+
+	// kubernetes cluster name is in locality only if clusters are set in config
+	if e.IsMultiCluster() {
+		switch *appConfig.Get().ClusterLocality {
+		case appConfig.ClusterLocalityRegion:
+			locality.Region = e.Cluster.Name
+		default:
+			locality.SubZone = e.Cluster.Name
+		}
+	}
 
-	if envoyEndpoint.Item.Priority > 0 {
-		priority = envoyEndpoint.Item.Priority
+	return locality
+}
+
+func (e *envoyEndpoint) IsMultiCluster() bool {
+	return len(e.Item.Clusters) > 0
+}
+
+func (e *envoyEndpoint) GetPriority() uint32 {
+	if e.Cluster.Priority > 0 {
+		return e.Cluster.Priority
 	}
 
+	return e.Item.Priority
+}
+
+func (cs *ConfigStore) getEnvoyLocalityLbEndpoint(envoyEndpoint *envoyEndpoint) *endpoint.LocalityLbEndpoints { //nolint:lll
 	healthCheckConfig := &endpoint.Endpoint_HealthCheckConfig{}
 
 	if envoyEndpoint.Item.HealthCheckPort > 0 {
@@ -254,6 +486,14 @@ func (cs *ConfigStore) getEnvoyLocalityLbEndpoint(envoyEndpoint *envoyEndpoint)
 		},
 	}
 
+	if envoyEndpoint.IsMultiCluster() {
+		metadataEnvoyLB[envoyMetaClusterName] = &structpb.Value{
+			Kind: &structpb.Value_StringValue{
+				StringValue: envoyEndpoint.Cluster.Name,
+			},
+		}
+	}
+
 	// add all metadata
 	for k, v := range envoyEndpoint.Metadata {
 		metadataEnvoyLB[k] = &structpb.Value{
@@ -264,9 +504,10 @@ func (cs *ConfigStore) getEnvoyLocalityLbEndpoint(envoyEndpoint *envoyEndpoint)
 	}
 
 	return &endpoint.LocalityLbEndpoints{
-		Locality: cs.getEndpointLocality(envoyEndpoint.Node),
-		Priority: priority,
+		Locality: envoyEndpoint.GetLocality(cs),
+		Priority: envoyEndpoint.GetPriority(),
 		LbEndpoints: []*endpoint.LbEndpoint{{
+			HealthStatus: envoyEndpoint.HealthStatus,
 			Metadata: &core.Metadata{
 				FilterMetadata: map[string]*structpb.Struct{
 					"envoy.lb": {
@@ -317,36 +558,39 @@ func (cs *ConfigStore) getLocalityLbEndpoints() (map[string][]*endpoint.Locality
 			continue
 		}
 
-		pods, err := api.ListPods(kubernetes.Selector)
-		if err != nil {
-			return nil, errors.Wrap(err, "error getting pods")
-		}
-
-		for _, pod := range pods {
-			// ignore pod if it has no IP or no node
-			if len(pod.Status.PodIP) == 0 || len(pod.Spec.NodeName) == 0 {
-				continue
+		for _, cluster := range kubernetes.GetClusters() {
+			pods, err := api.ListClusterPods(cluster.Name, kubernetes.Namespace, kubernetes.Selector)
+			if err != nil {
+				return nil, errors.Wrap(err, "error getting pods")
 			}
 
-			// ignore pod if deleted
-			if pod.DeletionTimestamp != nil {
-				continue
-			}
+			for _, pod := range pods {
+				// ignore pod if it has no IP or no node
+				if len(pod.Status.PodIP) == 0 || len(pod.Spec.NodeName) == 0 {
+					continue
+				}
 
-			// ignore pod if not ready
-			if !cs.isPodReady(pod) {
-				continue
-			}
+				isReady := cs.isPodReady(pod)
+				isTerminating := pod.DeletionTimestamp != nil
 
-			// get envoy endpoint
-			lbEndpoints[kubernetes.ClusterName] = append(lbEndpoints[kubernetes.ClusterName], cs.getEnvoyLocalityLbEndpoint(&envoyEndpoint{ //nolint:lll
-				IsCanary: false,
-				Node:     pod.Spec.NodeName,
-				Address:  pod.Status.PodIP,
-				Item:     kubernetes,
-				Metadata: cs.getEnvoyMetaFromPod(pod),
-			},
-			))
+				// ignore pod if deleted or not ready, unless health status publishing is enabled
+				healthStatus, ok := getHealthStatus(kubernetes, isReady && !isTerminating, isReady, isTerminating)
+				if !ok {
+					continue
+				}
+
+				// get envoy endpoint
+				lbEndpoints[kubernetes.ClusterName] = append(lbEndpoints[kubernetes.ClusterName], cs.getEnvoyLocalityLbEndpoint(&envoyEndpoint{ //nolint:lll
+					IsCanary:     false,
+					Cluster:      cluster,
+					Node:         pod.Spec.NodeName,
+					Address:      pod.Status.PodIP,
+					Item:         kubernetes,
+					Metadata:     cs.getEnvoyMetaFromPod(pod),
+					HealthStatus: healthStatus,
+				},
+				))
+			}
 		}
 	}
 
@@ -357,68 +601,42 @@ func (cs *ConfigStore) getLocalityLbEndpoints() (map[string][]*endpoint.Locality
 			continue
 		}
 
-		// get endpoints by service name
-		endpoints, err := api.GetEndpoint(kubernetes.Service)
-		if err != nil {
-			return nil, errors.Wrap(err, "error getting endpoints")
-		}
-
-		// service not found
-		if endpoints == nil {
-			log.Debugf("service not found: %s", kubernetes.Service)
-
-			continue
-		}
-
-		for _, subset := range endpoints.Subsets {
-			for _, address := range subset.Addresses {
-				newEp := &envoyEndpoint{
-					IsCanary: false,
-					Address:  address.IP,
-					Item:     kubernetes,
-					Metadata: cs.getEnvoyMetaFromEndpoint(address),
-				}
-
-				newEp.SetNode(address)
-
-				// get envoy endpoint
-				lbEndpoints[kubernetes.ClusterName] = append(
-					lbEndpoints[kubernetes.ClusterName],
-					cs.getEnvoyLocalityLbEndpoint(newEp),
-				)
+		for _, cluster := range kubernetes.GetClusters() {
+			// get endpoints by service name
+			endpointSlices, err := api.GetClusterEndpointSlices(cluster.Name, kubernetes.Namespace, kubernetes.Service)
+			if err != nil {
+				return nil, errors.Wrap(err, "error getting endpoints")
 			}
-		}
 
-		// get canary endpoints by service name
-		endpointsCanary, err := api.GetEndpoint(kubernetes.Service + appConfig.CanarySuffix)
-		if err != nil {
-			return nil, errors.Wrap(err, "error getting endpoints")
-		}
+			// service not found
+			if endpointSlices == nil {
+				log.Debugf("service not found: %s, cluster: %s", kubernetes.Service, cluster.Name)
 
-		// service not found
-		if endpointsCanary == nil {
-			log.Debugf("canary service not found: %s", kubernetes.Service)
+				continue
+			}
 
-			continue
-		}
+			lbEndpoints[kubernetes.ClusterName] = append(
+				lbEndpoints[kubernetes.ClusterName],
+				cs.getEnvoyLocalityLbEndpointsFromSlices(kubernetes, cluster, endpointSlices, false)...,
+			)
 
-		for _, subset := range endpointsCanary.Subsets {
-			for _, address := range subset.Addresses {
-				newEp := &envoyEndpoint{
-					IsCanary: true,
-					Address:  address.IP,
-					Item:     kubernetes,
-					Metadata: cs.getEnvoyMetaFromEndpoint(address),
-				}
+			// get canary endpoints by service name
+			endpointSlicesCanary, err := api.GetClusterEndpointSlices(cluster.Name, kubernetes.Namespace, kubernetes.Service+appConfig.CanarySuffix) //nolint:lll
+			if err != nil {
+				return nil, errors.Wrap(err, "error getting endpoints")
+			}
 
-				newEp.SetNode(address)
+			// service not found
+			if endpointSlicesCanary == nil {
+				log.Debugf("canary service not found: %s, cluster: %s", kubernetes.Service, cluster.Name)
 
-				// get envoy endpoint
-				lbEndpoints[kubernetes.ClusterName] = append(
-					lbEndpoints[kubernetes.ClusterName],
-					cs.getEnvoyLocalityLbEndpoint(newEp),
-				)
+				continue
 			}
+
+			lbEndpoints[kubernetes.ClusterName] = append(
+				lbEndpoints[kubernetes.ClusterName],
+				cs.getEnvoyLocalityLbEndpointsFromSlices(kubernetes, cluster, endpointSlicesCanary, true)...,
+			)
 		}
 	}
 
@@ -444,12 +662,97 @@ func (cs *ConfigStore) getEnvoyMetaFromPod(pod *corev1.Pod) map[string]string {
 	return labels
 }
 
-func (cs *ConfigStore) getEnvoyMetaFromEndpoint(address corev1.EndpointAddress) map[string]string {
+// merge all slices of one service, endpoint can be in several slices while they are updating.
+func (cs *ConfigStore) getEnvoyLocalityLbEndpointsFromSlices(kubernetes appConfig.KubernetesType, cluster appConfig.KubernetesClusterType, endpointSlices []*discoveryv1.EndpointSlice, isCanary bool) []*endpoint.LocalityLbEndpoints { //nolint:lll
+	result := make([]*endpoint.LocalityLbEndpoints, 0)
+	seen := make(map[string]bool)
+
+	for _, endpointSlice := range endpointSlices {
+		// FQDN slices has no IP addresses
+		if endpointSlice.AddressType == discoveryv1.AddressTypeFQDN {
+			continue
+		}
+
+		for _, ep := range endpointSlice.Endpoints {
+			// ignore endpoint if terminating or not ready, unless health status publishing is enabled
+			healthStatus, ok := getHealthStatus(kubernetes, isEndpointReady(ep), isEndpointServing(ep), isEndpointTerminating(ep)) //nolint:lll
+			if !ok {
+				continue
+			}
+
+			for _, address := range ep.Addresses {
+				if seen[address] {
+					continue
+				}
+
+				seen[address] = true
+
+				newEp := &envoyEndpoint{
+					IsCanary:     isCanary,
+					Cluster:      cluster,
+					Address:      address,
+					Item:         kubernetes,
+					Metadata:     cs.getEnvoyMetaFromEndpoint(cluster.Name, ep, address),
+					HealthStatus: healthStatus,
+				}
+
+				newEp.SetNode(ep)
+
+				// get envoy endpoint
+				result = append(result, cs.getEnvoyLocalityLbEndpoint(newEp))
+			}
+		}
+	}
+
+	return result
+}
+
+// nil conditions.ready must be interpreted as ready.
+func isEndpointReady(ep discoveryv1.Endpoint) bool {
+	return ep.Conditions.Ready == nil || *ep.Conditions.Ready
+}
+
+// nil conditions.serving must be interpreted as ready.
+func isEndpointServing(ep discoveryv1.Endpoint) bool {
+	if ep.Conditions.Serving == nil {
+		return isEndpointReady(ep)
+	}
+
+	return *ep.Conditions.Serving
+}
+
+func isEndpointTerminating(ep discoveryv1.Endpoint) bool {
+	return ep.Conditions.Terminating != nil && *ep.Conditions.Terminating
+}
+
+// return envoy health status of endpoint, false if endpoint must not be published.
+func getHealthStatus(kubernetes appConfig.KubernetesType, isReady, isServing, isTerminating bool) (core.HealthStatus, bool) { //nolint:lll
+	switch {
+	case isTerminating:
+		// terminating endpoint that still serving traffic must be drained
+		if kubernetes.PublishTerminating && isServing {
+			return core.HealthStatus_DRAINING, true
+		}
+
+		return core.HealthStatus_UNKNOWN, false
+	case !isReady:
+		if kubernetes.PublishNotReady {
+			return core.HealthStatus_UNHEALTHY, true
+		}
+
+		return core.HealthStatus_UNKNOWN, false
+	default:
+		// envoy active health checks decide endpoint health
+		return core.HealthStatus_UNKNOWN, true
+	}
+}
+
+func (cs *ConfigStore) getEnvoyMetaFromEndpoint(cluster string, ep discoveryv1.Endpoint, address string) map[string]string { //nolint:lll
 	labels := make(map[string]string)
 
-	if address.TargetRef != nil && address.TargetRef.Kind == "Pod" {
+	if ep.TargetRef != nil && ep.TargetRef.Kind == "Pod" {
 		// add pod labels to envoy metadata
-		pod, err := api.GetPod(address.TargetRef.Namespace, address.TargetRef.Name)
+		pod, err := api.GetClusterPod(cluster, ep.TargetRef.Namespace, ep.TargetRef.Name)
 		if err != nil {
 			log.WithError(err).Error("error getting pod")
 		} else if pod != nil && pod.Labels != nil {
@@ -460,14 +763,31 @@ func (cs *ConfigStore) getEnvoyMetaFromEndpoint(address corev1.EndpointAddress)
 			}
 		}
 
-		labels[envoyMetaPodName] = address.TargetRef.Name
+		labels[envoyMetaPodName] = ep.TargetRef.Name
 	}
 
-	if address.NodeName != nil {
-		labels[envoyMetaNodeName] = *address.NodeName
+	if ep.NodeName != nil {
+		labels[envoyMetaNodeName] = *ep.NodeName
+	}
+
+	if ep.Zone != nil {
+		labels[envoyMetaZone] = *ep.Zone
+	}
+
+	if ep.Hints != nil && len(ep.Hints.ForZones) > 0 {
+		zones := make([]string, 0, len(ep.Hints.ForZones))
+
+		for _, zone := range ep.Hints.ForZones {
+			zones = append(zones, zone.Name)
+		}
+
+		labels[envoyMetaHintsZones] = strings.Join(zones, ",")
 	}
 
-	labels[envoyMetaEndpointIP] = address.IP
+	labels[envoyMetaReady] = strconv.FormatBool(isEndpointReady(ep))
+	labels[envoyMetaServing] = strconv.FormatBool(isEndpointServing(ep))
+	labels[envoyMetaTerminating] = strconv.FormatBool(isEndpointTerminating(ep))
+	labels[envoyMetaEndpointIP] = address
 
 	return labels
 }
@@ -476,6 +796,52 @@ func (cs *ConfigStore) getEnvoyMetaFromEndpoint(address corev1.EndpointAddress)
 func (cs *ConfigStore) saveLastEndpoints(ctx context.Context) {
 	defer utils.TimeTrack("saveLastEndpoints", time.Now())
 
+	publishEp, publishEpArray, invalidIPs, err := cs.getPublishEndpoints()
+	if err != nil {
+		log.WithError(err).Error(err)
+
+		return
+	}
+
+	for _, invalidIP := range invalidIPs {
+		cs.log.Errorf("clusterName=%s,ip=%s is invalid", invalidIP.clusterName, invalidIP.address)
+
+		cs.Event(corev1.EventTypeWarning, api.EventReasonInvalidEndpointIP, "node %s cluster %s has invalid endpoint ip %q", cs.Config.ID, invalidIP.clusterName, invalidIP.address) //nolint:lll
+	}
+
+	if len(invalidIPs) > 0 {
+		log.WithError(errInvalidIP).Warn()
+
+		return
+	}
+
+	publishEpVersion, err := utils.GetResourcesVersion(publishEp)
+	if err != nil {
+		cs.log.WithError(err).Error()
+
+		return
+	}
+
+	cs.mutex.Lock()
+	defer cs.mutex.Unlock()
+
+	if cs.lastEndpointsVersion != publishEpVersion {
+		cs.lastEndpoints = publishEp
+		cs.lastEndpointsArray = publishEpArray
+		cs.lastEndpointsVersion = publishEpVersion
+
+		// endpoints changes
+		go cs.Push(ctx, "new endpoints")
+	}
+}
+
+type invalidEndpointIP struct {
+	clusterName string
+	address     string
+}
+
+// endpoints of config and kubernetes endpoints, returns endpoints with invalid ip separately.
+func (cs *ConfigStore) getPublishEndpoints() ([]types.Resource, []string, []invalidEndpointIP, error) {
 	lbEndpoints := make(map[string][]*endpoint.LocalityLbEndpoints)
 	// copy map
 	for key, value := range cs.configEndpoints {
@@ -484,9 +850,7 @@ func (cs *ConfigStore) saveLastEndpoints(ctx context.Context) {
 
 	endpoints, err := cs.getLocalityLbEndpoints()
 	if err != nil {
-		log.WithError(err).Error(err)
-
-		return
+		return nil, nil, nil, err
 	}
 
 	// append endpoints
@@ -494,29 +858,31 @@ func (cs *ConfigStore) saveLastEndpoints(ctx context.Context) {
 		lbEndpoints[key] = append(lbEndpoints[key], value...)
 	}
 
-	isInvalidIP := false
+	invalidIPs := make([]invalidEndpointIP, 0)
 	publishEp := []types.Resource{}
-	publishEpArray := []string{} // for reflect.DeepEqual
+	publishEpArray := []string{} // for GetLastEndpoints
 
 	for clusterName, ep := range lbEndpoints {
+		// informers return endpoints in random order, same endpoints must have same hash
+		ep = sortLocalityLbEndpoints(ep)
+
 		for _, value1 := range ep {
 			for _, value2 := range value1.GetLbEndpoints() {
 				address := value2.GetEndpoint().GetAddress().GetSocketAddress().GetAddress()
 
 				publishEpArray = append(publishEpArray, fmt.Sprintf(
-					"%s|%s|%d|%s|%d|%d",
+					"%s|%s|%d|%s|%d|%d|%s",
 					clusterName,
 					value1.GetLocality().GetZone(),
 					value1.GetPriority(),
 					value2.GetEndpoint().GetAddress().GetSocketAddress().GetAddress(),
 					value2.GetEndpoint().GetAddress().GetSocketAddress().GetPortValue(),
 					value2.GetEndpoint().GetHealthCheckConfig().GetPortValue(),
+					value2.GetHealthStatus().String(),
 				))
 
 				if net.ParseIP(address) == nil {
-					isInvalidIP = true
-
-					cs.log.Errorf("clusterName=%s,ip=%s is invalid", clusterName, address)
+					invalidIPs = append(invalidIPs, invalidEndpointIP{clusterName: clusterName, address: address})
 				}
 			}
 		}
@@ -529,27 +895,102 @@ func (cs *ConfigStore) saveLastEndpoints(ctx context.Context) {
 		publishEp = append(publishEp, &clusterLoadAssignment)
 	}
 
-	if isInvalidIP {
-		log.WithError(errInvalidIP).Warn()
+	sort.Strings(publishEpArray)
 
-		return
+	return publishEp, publishEpArray, invalidIPs, nil
+}
+
+// snapshot that config store will push with current endpoints, nothing is pushed.
+func (cs *ConfigStore) DryRun() (*cache.Snapshot, error) {
+	publishEp, _, invalidIPs, err := cs.getPublishEndpoints()
+	if err != nil {
+		return nil, errors.Wrap(err, "error in getPublishEndpoints")
 	}
 
-	// reflect.DeepEqual only on sorted values
-	sort.Strings(publishEpArray)
+	if len(invalidIPs) > 0 {
+		return nil, errors.Wrapf(errInvalidIP, "cluster %s ip %q", invalidIPs[0].clusterName, invalidIPs[0].address)
+	}
+
+	snap, err := utils.GetHashedConfigSnapshot(cs.Config, publishEp, cs.secrets)
+	if err != nil {
+		return nil, errors.Wrap(err, "error in GetHashedConfigSnapshot")
+	}
 
+	return snap, nil
+}
+
+// sorted copy of endpoints by locality and priority, lb endpoints are sorted by address and port.
+func sortLocalityLbEndpoints(ep []*endpoint.LocalityLbEndpoints) []*endpoint.LocalityLbEndpoints {
+	result := make([]*endpoint.LocalityLbEndpoints, 0, len(ep))
+	keys := make(map[*endpoint.LocalityLbEndpoints]string, len(ep))
+
+	for _, value := range ep {
+		sorted, ok := proto.Clone(value).(*endpoint.LocalityLbEndpoints)
+		if !ok {
+			log.WithError(errAssertion).Fatal("proto.Clone(value).(*endpoint.LocalityLbEndpoints)")
+		}
+
+		sort.SliceStable(sorted.GetLbEndpoints(), func(i, j int) bool {
+			return getLbEndpointKey(sorted.GetLbEndpoints()[i]) < getLbEndpointKey(sorted.GetLbEndpoints()[j])
+		})
+
+		lbEndpointKeys := make([]string, 0, len(sorted.GetLbEndpoints()))
+
+		for _, lbEndpoint := range sorted.GetLbEndpoints() {
+			lbEndpointKeys = append(lbEndpointKeys, getLbEndpointKey(lbEndpoint))
+		}
+
+		keys[sorted] = fmt.Sprintf(
+			"%s|%s|%s|%010d|%s",
+			sorted.GetLocality().GetRegion(),
+			sorted.GetLocality().GetZone(),
+			sorted.GetLocality().GetSubZone(),
+			sorted.GetPriority(),
+			strings.Join(lbEndpointKeys, ","),
+		)
+
+		result = append(result, sorted)
+	}
+
+	sort.SliceStable(result, func(i, j int) bool {
+		return keys[result[i]] < keys[result[j]]
+	})
+
+	return result
+}
+
+func getLbEndpointKey(lbEndpoint *endpoint.LbEndpoint) string {
+	socketAddress := lbEndpoint.GetEndpoint().GetAddress().GetSocketAddress()
+
+	return fmt.Sprintf("%s|%010d", socketAddress.GetAddress(), socketAddress.GetPortValue())
+}
+
+func (cs *ConfigStore) GetVersion() string {
 	cs.mutex.Lock()
 	defer cs.mutex.Unlock()
 
-	if !reflect.DeepEqual(cs.lastEndpointsArray, publishEpArray) {
-		cs.lastEndpoints = publishEp
-		cs.lastEndpointsArray = publishEpArray
+	return cs.Version
+}
 
-		// endpoints changes
-		go cs.Push(ctx, "new endpoints")
+// annotations that do not change resources are applied to running store.
+func (cs *ConfigStore) SetConfigMapAnnotations(annotations map[string]string) {
+	cs.mutex.Lock()
+	defer cs.mutex.Unlock()
+
+	cs.Config.ConfigMapAnnotations = annotations
+
+	for _, nodeConfig := range cs.nodeConfigs {
+		nodeConfig.config.ConfigMapAnnotations = annotations
 	}
 }
 
+func (cs *ConfigStore) getConfigMapAnnotations() map[string]string {
+	cs.mutex.Lock()
+	defer cs.mutex.Unlock()
+
+	return cs.Config.ConfigMapAnnotations
+}
+
 func (cs *ConfigStore) GetLastEndpoints() []string {
 	cs.mutex.Lock()
 	defer cs.mutex.Unlock()
@@ -557,6 +998,17 @@ func (cs *ConfigStore) GetLastEndpoints() []string {
 	return cs.lastEndpointsArray
 }
 
+// create kubernetes event on config source.
+func (cs *ConfigStore) Event(eventType, reason, messageFmt string, args ...interface{}) {
+	if len(cs.Config.ConfigMapName) == 0 {
+		return
+	}
+
+	ref := api.ConfigSourceReference(cs.Config.ConfigSourceKind, cs.Config.ConfigMapNamespace, cs.Config.ConfigMapName)
+
+	api.Event(ref, eventType, reason, messageFmt, args...)
+}
+
 func (cs *ConfigStore) Stop() {
 	cs.log.Info("stop")
 	cs.isStoped.Store(true)
@@ -572,19 +1024,22 @@ func (cs *ConfigStore) Sync(ctx context.Context) {
 	cs.mutex.Lock()
 	defer cs.mutex.Unlock()
 
-	if cs.lastEndpoints != nil {
+	// snapshot pinned by user differs from store by design
+	if cs.lastEndpoints != nil && !isPinned(cs.Config.ID) {
 		snap, err := controlplane.SnapshotCache.GetSnapshot(cs.Config.ID)
 		if err != nil {
 			log.WithError(err).Warn()
 		}
 
 		snapVersion := snap.GetVersion(resource.EndpointType)
+		storeVersion := cs.snapshot.GetVersion(resource.EndpointType)
 
-		if len(snapVersion) > 0 && snapVersion != cs.Version {
-			log.Warnf("nodeID=%s,version not match %s,%s", cs.Config.ID, snapVersion, cs.Version)
+		if len(snapVersion) > 0 && snapVersion != storeVersion {
+			log.Warnf("nodeID=%s,version not match %s,%s", cs.Config.ID, snapVersion, storeVersion)
 
 			cs.lastEndpoints = nil
 			cs.lastEndpointsArray = nil
+			cs.lastEndpointsVersion = ""
 
 			go cs.saveLastEndpoints(ctx)
 		}
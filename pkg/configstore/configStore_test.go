@@ -0,0 +1,125 @@
+/*
+Copyright paskal.maksim@gmail.com
+Licensed under the Apache License, Version 2.0 (the "License")
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package configstore_test
+
+import (
+	"testing"
+
+	core "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
+	endpoint "github.com/envoyproxy/go-control-plane/envoy/config/endpoint/v3"
+	"github.com/envoyproxy/go-control-plane/pkg/cache/types"
+	"github.com/maksim-paskal/envoy-control-plane/pkg/config"
+	"github.com/maksim-paskal/envoy-control-plane/pkg/configstore"
+	"github.com/maksim-paskal/envoy-control-plane/pkg/utils"
+)
+
+func TestGetHealthStatus(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name          string
+		kubernetes    config.KubernetesType
+		isReady       bool
+		isServing     bool
+		isTerminating bool
+		status        core.HealthStatus
+		publish       bool
+	}{
+		{name: "ready", isReady: true, isServing: true, status: core.HealthStatus_UNKNOWN, publish: true},
+		{name: "not ready", status: core.HealthStatus_UNKNOWN},
+		{
+			name:       "not ready published",
+			kubernetes: config.KubernetesType{PublishNotReady: true},
+			status:     core.HealthStatus_UNHEALTHY,
+			publish:    true,
+		},
+		{name: "terminating", isServing: true, isTerminating: true, status: core.HealthStatus_UNKNOWN},
+		{
+			name:          "terminating serving published",
+			kubernetes:    config.KubernetesType{PublishTerminating: true},
+			isServing:     true,
+			isTerminating: true,
+			status:        core.HealthStatus_DRAINING,
+			publish:       true,
+		},
+		{
+			name:          "terminating not serving",
+			kubernetes:    config.KubernetesType{PublishTerminating: true, PublishNotReady: true},
+			isTerminating: true,
+			status:        core.HealthStatus_UNKNOWN,
+		},
+	}
+
+	for _, test := range tests {
+		status, publish := configstore.GetHealthStatus(test.kubernetes, test.isReady, test.isServing, test.isTerminating)
+
+		if status != test.status || publish != test.publish {
+			t.Fatalf("%s: got %s %t, want %s %t", test.name, status, publish, test.status, test.publish)
+		}
+	}
+}
+
+func newLbEndpoint(address string, port uint32) *endpoint.LbEndpoint {
+	return &endpoint.LbEndpoint{
+		HostIdentifier: &endpoint.LbEndpoint_Endpoint{
+			Endpoint: &endpoint.Endpoint{
+				Address: &core.Address{
+					Address: &core.Address_SocketAddress{
+						SocketAddress: &core.SocketAddress{
+							Address:       address,
+							PortSpecifier: &core.SocketAddress_PortValue{PortValue: port},
+						},
+					},
+				},
+			},
+		},
+	}
+}
+
+func TestSortLocalityLbEndpoints(t *testing.T) {
+	t.Parallel()
+
+	zoneA := &core.Locality{Zone: "a"}
+	zoneB := &core.Locality{Zone: "b"}
+
+	getVersion := func(ep []*endpoint.LocalityLbEndpoints) string {
+		t.Helper()
+
+		version, err := utils.GetResourcesVersion([]types.Resource{&endpoint.ClusterLoadAssignment{
+			ClusterName: "test",
+			Endpoints:   configstore.SortLocalityLbEndpoints(ep),
+		}})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		return version
+	}
+
+	// same endpoints in informer order
+	version1 := getVersion([]*endpoint.LocalityLbEndpoints{
+		{Locality: zoneB, LbEndpoints: []*endpoint.LbEndpoint{newLbEndpoint("10.0.0.2", 80), newLbEndpoint("10.0.0.1", 80)}},
+		{Locality: zoneA, LbEndpoints: []*endpoint.LbEndpoint{newLbEndpoint("10.0.0.3", 80)}},
+		{Locality: zoneA, Priority: 1, LbEndpoints: []*endpoint.LbEndpoint{newLbEndpoint("10.0.0.4", 80)}},
+	})
+
+	version2 := getVersion([]*endpoint.LocalityLbEndpoints{
+		{Locality: zoneA, Priority: 1, LbEndpoints: []*endpoint.LbEndpoint{newLbEndpoint("10.0.0.4", 80)}},
+		{Locality: zoneA, LbEndpoints: []*endpoint.LbEndpoint{newLbEndpoint("10.0.0.3", 80)}},
+		{Locality: zoneB, LbEndpoints: []*endpoint.LbEndpoint{newLbEndpoint("10.0.0.1", 80), newLbEndpoint("10.0.0.2", 80)}},
+	})
+
+	if version1 != version2 {
+		t.Fatal("same endpoints must have same version")
+	}
+}
@@ -15,6 +15,10 @@ package configstore
 import "errors"
 
 var (
-	errInvalidIP = errors.New("can not push changes, isInvalidIP")
-	errAssertion = errors.New("assertion error")
+	errInvalidIP      = errors.New("can not push changes, isInvalidIP")
+	errAssertion      = errors.New("assertion error")
+	errUnknownCluster = errors.New("unknown kubernetes cluster")
+	errNoHistory      = errors.New("node has no snapshot in history with this version")
+	errNotPinned      = errors.New("node is not pinned")
+	errConfigNacked   = errors.New("rejected by envoy")
 )
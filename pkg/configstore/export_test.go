@@ -0,0 +1,31 @@
+/*
+Copyright paskal.maksim@gmail.com
+Licensed under the Apache License, Version 2.0 (the "License")
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package configstore
+
+// mark version as failed in history with entries, returns rollback version, pinned version and result of markFailed.
+func MarkFailed(entries []*HistoryEntry, pinned, version string) (string, string, bool) {
+	h := &nodeHistory{entries: entries, pinned: pinned}
+
+	target, ok := h.markFailed(version, "rejected")
+	if target == nil {
+		return "", h.pinned, ok
+	}
+
+	return target.Version, h.pinned, ok
+}
+
+var (
+	GetHealthStatus         = getHealthStatus
+	SortLocalityLbEndpoints = sortLocalityLbEndpoints
+	WithEndpoints           = withEndpoints
+)
@@ -0,0 +1,244 @@
+/*
+Copyright paskal.maksim@gmail.com
+Licensed under the Apache License, Version 2.0 (the "License")
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package configstore
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/envoyproxy/go-control-plane/pkg/cache/v3"
+	"github.com/maksim-paskal/envoy-control-plane/pkg/config"
+	"github.com/maksim-paskal/envoy-control-plane/pkg/controlplane"
+	"github.com/maksim-paskal/envoy-control-plane/pkg/snapshotstore"
+	"github.com/maksim-paskal/envoy-control-plane/pkg/utils"
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+)
+
+type HistoryEntry struct {
+	Version string
+	Reason  string
+	Time    time.Time
+	// source ConfigMap or EnvoyNodeConfig
+	Source          string
+	ResourceVersion string
+	// all envoys acknowledged snapshot
+	Acked bool
+	// envoy rejected snapshot
+	Failed   bool
+	Error    string `json:",omitempty"`
+	snapshot *cache.Snapshot
+}
+
+type nodeHistory struct {
+	mutex   sync.RWMutex
+	entries []*HistoryEntry
+	// pinned version is served until unpin
+	pinned string
+	// version was pinned by rollback, new config releases it
+	rollback bool
+}
+
+// pushed snapshots by node hash.
+var history = new(sync.Map)
+
+func getNodeHistory(nodeHash string) *nodeHistory {
+	value, _ := history.LoadOrStore(nodeHash, &nodeHistory{})
+
+	h, ok := value.(*nodeHistory)
+	if !ok {
+		log.WithError(errAssertion).Fatal("getNodeHistory value.(*nodeHistory)")
+	}
+
+	return h
+}
+
+// save snapshot in history, returns pinned version if node is pinned and true if it was pinned by rollback.
+func (cs *ConfigStore) addHistory(nodeHash string, snap *cache.Snapshot, reason string) (string, bool) {
+	h := getNodeHistory(nodeHash)
+
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	version := utils.GetSnapshotVersion(snap)
+
+	if len(h.entries) == 0 || h.entries[len(h.entries)-1].Version != version {
+		h.entries = append(h.entries, &HistoryEntry{
+			Version:         version,
+			Reason:          reason,
+			Time:            time.Now(),
+			Source:          cs.getSource(),
+			ResourceVersion: cs.Config.ConfigMapResourceVersion,
+			snapshot:        snap,
+		})
+	}
+
+	// history size limit
+	if size := *config.Get().SnapshotHistory; size > 0 && len(h.entries) > size {
+		h.entries = h.entries[len(h.entries)-size:]
+	}
+
+	return h.pinned, h.rollback
+}
+
+// pushed snapshots of node, last is latest.
+func GetHistory(nodeHash string) ([]*HistoryEntry, string) {
+	value, ok := history.Load(nodeHash)
+	if !ok {
+		return nil, ""
+	}
+
+	h, ok := value.(*nodeHistory)
+	if !ok {
+		return nil, ""
+	}
+
+	h.mutex.RLock()
+	defer h.mutex.RUnlock()
+
+	entries := make([]*HistoryEntry, 0, len(h.entries))
+
+	// copy entries, acknowledge state can change
+	for _, entry := range h.entries {
+		e := *entry
+		entries = append(entries, &e)
+	}
+
+	return entries, h.pinned
+}
+
+// snapshot from history, empty version returns latest snapshot.
+func GetHistorySnapshot(nodeHash, version string) (*cache.Snapshot, error) {
+	entries, _ := GetHistory(nodeHash)
+
+	for i := len(entries) - 1; i >= 0; i-- {
+		if len(version) == 0 || entries[i].Version == version {
+			return entries[i].snapshot, nil
+		}
+	}
+
+	return nil, errors.Wrap(errNoHistory, version)
+}
+
+// node serves version pinned by user, rolled back node receives endpoints of store.
+func isPinned(nodeHash string) bool {
+	value, ok := history.Load(nodeHash)
+	if !ok {
+		return false
+	}
+
+	h, ok := value.(*nodeHistory)
+	if !ok {
+		return false
+	}
+
+	h.mutex.RLock()
+	defer h.mutex.RUnlock()
+
+	return len(h.pinned) > 0 && !h.rollback
+}
+
+// running config store of node hash, nil if not found.
+func getConfigStore(nodeHash string) *ConfigStore {
+	configID := strings.SplitN(nodeHash, "/", 2)[0] //nolint:gomnd
+
+	value, ok := StoreMap.Load(configID)
+	if !ok {
+		return nil
+	}
+
+	cs, ok := value.(*ConfigStore)
+	if !ok {
+		return nil
+	}
+
+	return cs
+}
+
+// serve snapshot from history until unpin, new configs are saved in history only.
+func Pin(ctx context.Context, nodeHash, version string) error {
+	// push of config store must not overwrite pinned snapshot
+	if cs := getConfigStore(nodeHash); cs != nil {
+		cs.mutex.Lock()
+		defer cs.mutex.Unlock()
+	}
+
+	snap, err := GetHistorySnapshot(nodeHash, version)
+	if err != nil {
+		return err
+	}
+
+	h := getNodeHistory(nodeHash)
+
+	h.mutex.Lock()
+	h.pinned = version
+	h.rollback = false
+	h.mutex.Unlock()
+
+	if err := pushHistorySnapshot(ctx, nodeHash, snap); err != nil {
+		return err
+	}
+
+	log.Warnf("node %s pinned to version %s", nodeHash, version)
+
+	return nil
+}
+
+// serve latest snapshot from history.
+func Unpin(ctx context.Context, nodeHash string) error {
+	if cs := getConfigStore(nodeHash); cs != nil {
+		cs.mutex.Lock()
+		defer cs.mutex.Unlock()
+	}
+
+	h := getNodeHistory(nodeHash)
+
+	h.mutex.Lock()
+	pinned := h.pinned
+	h.pinned = ""
+	h.rollback = false
+	h.mutex.Unlock()
+
+	if len(pinned) == 0 {
+		return errNotPinned
+	}
+
+	snap, err := GetHistorySnapshot(nodeHash, "")
+	if err != nil {
+		return err
+	}
+
+	if err := pushHistorySnapshot(ctx, nodeHash, snap); err != nil {
+		return err
+	}
+
+	log.Infof("node %s unpinned from version %s", nodeHash, pinned)
+
+	return nil
+}
+
+func pushHistorySnapshot(ctx context.Context, nodeHash string, snap *cache.Snapshot) error {
+	if err := controlplane.SnapshotCache.SetSnapshot(ctx, nodeHash, snap); err != nil {
+		return errors.Wrap(err, "error in SetSnapshot")
+	}
+
+	snapshotstore.SaveAsync(ctx, nodeHash, snap)
+
+	return nil
+}
+
+func DeleteHistory(nodeHash string) {
+	history.Delete(nodeHash)
+}
@@ -0,0 +1,174 @@
+/*
+Copyright paskal.maksim@gmail.com
+Licensed under the Apache License, Version 2.0 (the "License")
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package configstore
+
+import (
+	"context"
+
+	core "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
+	tls "github.com/envoyproxy/go-control-plane/envoy/extensions/transport_sockets/tls/v3"
+	appConfig "github.com/maksim-paskal/envoy-control-plane/pkg/config"
+	"github.com/maksim-paskal/envoy-control-plane/pkg/controlplane"
+	"github.com/maksim-paskal/envoy-control-plane/pkg/snapshotstore"
+	"github.com/maksim-paskal/envoy-control-plane/pkg/utils"
+	"github.com/pkg/errors"
+)
+
+// config rendered with data of connected envoy.
+type nodeConfig struct {
+	node   *core.Node
+	config *appConfig.ConfigType
+}
+
+// only configs from templates can be rendered per node.
+func (cs *ConfigStore) isPerNode() bool {
+	return cs.Config.PerNode && len(cs.Config.ConfigTemplate) > 0
+}
+
+// render config for connected envoy and push it.
+func (cs *ConfigStore) AddNode(ctx context.Context, node *core.Node) {
+	if cs.hasStoped() || !cs.isPerNode() {
+		return
+	}
+
+	if err := cs.addNode(node); err != nil {
+		cs.log.WithError(err).Errorf("error rendering config for node %s", controlplane.GetNodeHash(node))
+
+		SetConfigError(controlplane.GetNodeHash(node), cs.getSource(), err)
+
+		return
+	}
+
+	ClearConfigError(controlplane.GetNodeHash(node))
+
+	cs.pushNode(ctx, controlplane.GetNodeHash(node), "new node")
+}
+
+// push snapshot of rendered config of one node.
+func (cs *ConfigStore) pushNode(ctx context.Context, nodeHash, reason string) {
+	cs.mutex.Lock()
+	defer cs.mutex.Unlock()
+
+	nodeConfig, ok := cs.nodeConfigs[nodeHash]
+	if !ok {
+		return
+	}
+
+	nodeSnap, err := utils.GetHashedConfigSnapshot(nodeConfig.config, cs.lastEndpoints, cs.secrets)
+	if err != nil {
+		cs.log.WithError(err).Error()
+
+		return
+	}
+
+	cs.setSnapshot(ctx, nodeHash, nodeSnap, reason)
+}
+
+func (cs *ConfigStore) addNode(node *core.Node) error {
+	nodeHash := controlplane.GetNodeHash(node)
+
+	cs.mutex.Lock()
+	_, ok := cs.nodeConfigs[nodeHash]
+	secrets := cs.secrets
+	cs.mutex.Unlock()
+
+	if ok {
+		return nil
+	}
+
+	config, err := cs.renderNodeConfig(node, secrets)
+	if err != nil {
+		return err
+	}
+
+	cs.mutex.Lock()
+	defer cs.mutex.Unlock()
+
+	cs.nodeConfigs[nodeHash] = &nodeConfig{
+		node:   node,
+		config: config,
+	}
+
+	return nil
+}
+
+// remove config of disconnected envoy.
+func (cs *ConfigStore) RemoveNode(ctx context.Context, node *core.Node) {
+	nodeHash := controlplane.GetNodeHash(node)
+
+	cs.mutex.Lock()
+	delete(cs.nodeConfigs, nodeHash)
+	cs.mutex.Unlock()
+
+	DeleteConfigError(nodeHash)
+	DeleteHistory(nodeHash)
+
+	controlplane.SnapshotCache.ClearSnapshot(nodeHash)
+
+	snapshotstore.DeleteAsync(ctx, nodeHash)
+}
+
+// remove snapshots of all rendered configs.
+func (cs *ConfigStore) ClearNodeSnapshots(ctx context.Context) {
+	controlplane.SetPerNode(cs.Config.ID, false)
+
+	cs.mutex.Lock()
+	nodes := make([]*core.Node, 0, len(cs.nodeConfigs))
+
+	for _, nodeConfig := range cs.nodeConfigs {
+		nodes = append(nodes, nodeConfig.node)
+	}
+	cs.mutex.Unlock()
+
+	for _, node := range nodes {
+		cs.RemoveNode(ctx, node)
+	}
+}
+
+// kubernetes endpoints and secrets are shared with default config.
+func (cs *ConfigStore) renderNodeConfig(node *core.Node, secrets []tls.Secret) (*appConfig.ConfigType, error) {
+	config, err := appConfig.ParseConfigMapYaml(cs.Config.ConfigMapNamespace, cs.Config.ConfigTemplateName, cs.Config.ConfigTemplate, appConfig.NewTemplateData(node)) //nolint:lll
+	if err != nil {
+		return nil, err
+	}
+
+	config.ID = cs.Config.ID
+	config.Name = cs.Config.Name
+	config.PerNode = cs.Config.PerNode
+	config.VersionLabel = cs.Config.VersionLabel
+	config.ConfigSourceKind = cs.Config.ConfigSourceKind
+	config.ConfigMapName = cs.Config.ConfigMapName
+	config.ConfigMapNamespace = cs.Config.ConfigMapNamespace
+	config.ConfigMapAnnotations = cs.getConfigMapAnnotations()
+	config.ConfigMapResourceVersion = cs.Config.ConfigMapResourceVersion
+	config.Kubernetes = cs.Config.Kubernetes
+
+	if err := config.SaveResources(); err != nil {
+		return nil, errors.Wrap(err, "error in config.SaveResources")
+	}
+
+	snap, err := utils.GetHashedConfigSnapshot(config, nil, secrets)
+	if err != nil {
+		return nil, errors.Wrap(err, "error in GetHashedConfigSnapshot")
+	}
+
+	if err := utils.ValidateSnapshot(snap); err != nil {
+		return nil, errors.Wrap(err, "error in ValidateSnapshot")
+	}
+
+	return config, nil
+}
+
+func (cs *ConfigStore) getSource() string {
+	return cs.Config.ConfigSourceKind + "/" + cs.Config.ConfigMapNamespace + "/" + cs.Config.ConfigMapName
+}
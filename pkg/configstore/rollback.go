@@ -0,0 +1,215 @@
+/*
+Copyright paskal.maksim@gmail.com
+Licensed under the Apache License, Version 2.0 (the "License")
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package configstore
+
+import (
+	"context"
+	"strings"
+
+	"github.com/envoyproxy/go-control-plane/pkg/cache/v3"
+	"github.com/envoyproxy/go-control-plane/pkg/resource/v3"
+	"github.com/maksim-paskal/envoy-control-plane/pkg/api"
+	"github.com/maksim-paskal/envoy-control-plane/pkg/controlplane"
+	"github.com/maksim-paskal/envoy-control-plane/pkg/metrics"
+	"github.com/maksim-paskal/envoy-control-plane/pkg/utils"
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+	corev1 "k8s.io/api/core/v1"
+)
+
+// mark served snapshot as acknowledged if all envoys of node accepted it.
+func MarkAcked(nodeHash string) {
+	snap, err := controlplane.SnapshotCache.GetSnapshot(nodeHash)
+	if err != nil {
+		return
+	}
+
+	if !controlplane.IsAcked(nodeHash, snap) {
+		return
+	}
+
+	value, ok := history.Load(nodeHash)
+	if !ok {
+		return
+	}
+
+	h, ok := value.(*nodeHistory)
+	if !ok {
+		return
+	}
+
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	version := utils.GetSnapshotVersion(snap)
+
+	for _, entry := range h.entries {
+		if entry.Version == version && !entry.Failed {
+			entry.Acked = true
+		}
+	}
+}
+
+// serve last acknowledged snapshot of node when envoy rejects served snapshot.
+func (cs *ConfigStore) Rollback(ctx context.Context, nodeHash, typeURL, nackError string) {
+	if !cs.isRollbackEnabled() {
+		return
+	}
+
+	cs.mutex.Lock()
+	defer cs.mutex.Unlock()
+
+	current, err := controlplane.SnapshotCache.GetSnapshot(nodeHash)
+	if err != nil {
+		return
+	}
+
+	failedVersion := utils.GetSnapshotVersion(current)
+
+	target, ok := getNodeHistory(nodeHash).markFailed(failedVersion, nackError)
+	if !ok {
+		return
+	}
+
+	rejectErr := errors.Wrapf(errConfigNacked, "%s %s", typeURL, nackError)
+
+	SetConfigError(nodeHash, cs.getSource(), rejectErr)
+
+	if target == nil {
+		cs.log.WithError(rejectErr).Warnf("node %s has no acknowledged snapshot in history, skip rollback", nodeHash)
+
+		return
+	}
+
+	snap := target.snapshot
+
+	// only rejected config types are rolled back, envoy keeps receiving current endpoints
+	if typeURL != resource.EndpointType {
+		if snap, err = withEndpoints(target.snapshot, current); err != nil {
+			cs.log.WithError(err).Errorf("error rolling back %s to version %s", nodeHash, target.Version)
+
+			return
+		}
+	}
+
+	if err := pushHistorySnapshot(ctx, nodeHash, snap); err != nil {
+		cs.log.WithError(err).Errorf("error rolling back %s to version %s", nodeHash, target.Version)
+
+		return
+	}
+
+	metrics.ConfigmapsstoreRollback.WithLabelValues(nodeHash).Inc()
+
+	cs.Event(corev1.EventTypeWarning, api.EventReasonConfigRolledBack, "node %s rolled back from version %s to %s: %s", nodeHash, failedVersion, target.Version, rejectErr.Error()) //nolint:lll
+
+	cs.log.WithError(rejectErr).Warnf("node %s rolled back from version %s to %s", nodeHash, failedVersion, target.Version)
+}
+
+// snapshot of pinned version with endpoints of live snapshot.
+func getRollbackSnapshot(nodeHash, version string, live cache.ResourceSnapshot) (*cache.Snapshot, error) {
+	snap, err := GetHistorySnapshot(nodeHash, version)
+	if err != nil {
+		return nil, err
+	}
+
+	return withEndpoints(snap, live)
+}
+
+// replace endpoints of snapshot with endpoints of live snapshot.
+func withEndpoints(snap, live cache.ResourceSnapshot) (*cache.Snapshot, error) {
+	resources := utils.GetSnapshotResources(snap)
+	resources[resource.EndpointType] = utils.GetSnapshotResources(live)[resource.EndpointType]
+
+	result, err := utils.NewHashedSnapshot(resources)
+	if err != nil {
+		return nil, errors.Wrap(err, "error in NewHashedSnapshot")
+	}
+
+	return result, nil
+}
+
+func (cs *ConfigStore) isRollbackEnabled() bool {
+	cs.mutex.Lock()
+	defer cs.mutex.Unlock()
+
+	return cs.Config.IsRollbackEnabled()
+}
+
+// mark rejected version as failed and pin last acknowledged snapshot,
+// returns false if version was not marked.
+func (h *nodeHistory) markFailed(version, nackError string) (*HistoryEntry, bool) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	// pinned by user
+	if len(h.pinned) > 0 && !h.rollback {
+		return nil, false
+	}
+
+	failed := -1
+
+	for i := len(h.entries) - 1; i >= 0; i-- {
+		if h.entries[i].Version == version {
+			failed = i
+
+			break
+		}
+	}
+
+	// version is not from history or was already rolled back
+	if failed < 0 || h.entries[failed].Failed {
+		return nil, false
+	}
+
+	h.entries[failed].Failed = true
+	h.entries[failed].Acked = false
+	h.entries[failed].Error = nackError
+
+	for i := failed - 1; i >= 0; i-- {
+		if h.entries[i].Acked && !h.entries[i].Failed {
+			h.pinned = h.entries[i].Version
+			h.rollback = true
+
+			return h.entries[i], true
+		}
+	}
+
+	return nil, true
+}
+
+// new config of config id replaces rolled back snapshots.
+func ReleaseRollbacks(configID string) {
+	history.Range(func(key, value interface{}) bool {
+		nodeHash, ok := key.(string)
+		if !ok || (nodeHash != configID && !strings.HasPrefix(nodeHash, configID+"/")) {
+			return true
+		}
+
+		h, ok := value.(*nodeHistory)
+		if !ok {
+			return true
+		}
+
+		h.mutex.Lock()
+		defer h.mutex.Unlock()
+
+		if h.rollback {
+			log.Infof("node %s released from rollback version %s", nodeHash, h.pinned)
+
+			h.pinned = ""
+			h.rollback = false
+		}
+
+		return true
+	})
+}
@@ -0,0 +1,156 @@
+/*
+Copyright paskal.maksim@gmail.com
+Licensed under the Apache License, Version 2.0 (the "License")
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package configstore_test
+
+import (
+	"testing"
+
+	cluster "github.com/envoyproxy/go-control-plane/envoy/config/cluster/v3"
+	endpoint "github.com/envoyproxy/go-control-plane/envoy/config/endpoint/v3"
+	"github.com/envoyproxy/go-control-plane/pkg/cache/types"
+	"github.com/envoyproxy/go-control-plane/pkg/resource/v3"
+	"github.com/maksim-paskal/envoy-control-plane/pkg/configstore"
+	"github.com/maksim-paskal/envoy-control-plane/pkg/utils"
+)
+
+func TestMarkFailed(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name       string
+		entries    []*configstore.HistoryEntry
+		pinned     string
+		version    string
+		target     string
+		wantPinned string
+		ok         bool
+	}{
+		{
+			name: "rollback to last acked",
+			entries: []*configstore.HistoryEntry{
+				{Version: "v1", Acked: true},
+				{Version: "v2", Acked: true},
+				{Version: "v3"},
+			},
+			version:    "v3",
+			target:     "v2",
+			wantPinned: "v2",
+			ok:         true,
+		},
+		{
+			name: "skip failed versions",
+			entries: []*configstore.HistoryEntry{
+				{Version: "v1", Acked: true},
+				{Version: "v2", Failed: true},
+				{Version: "v3"},
+			},
+			version:    "v3",
+			target:     "v1",
+			wantPinned: "v1",
+			ok:         true,
+		},
+		{
+			name: "no acked version",
+			entries: []*configstore.HistoryEntry{
+				{Version: "v1"},
+				{Version: "v2"},
+			},
+			version: "v2",
+			ok:      true,
+		},
+		{
+			name: "already failed",
+			entries: []*configstore.HistoryEntry{
+				{Version: "v1", Acked: true},
+				{Version: "v2", Failed: true},
+			},
+			version: "v2",
+		},
+		{
+			name: "version not in history",
+			entries: []*configstore.HistoryEntry{
+				{Version: "v1", Acked: true},
+			},
+			version: "v2",
+		},
+		{
+			name: "pinned by user",
+			entries: []*configstore.HistoryEntry{
+				{Version: "v1", Acked: true},
+				{Version: "v2"},
+			},
+			pinned:     "v2",
+			version:    "v2",
+			wantPinned: "v2",
+		},
+	}
+
+	for _, test := range tests {
+		rollback, pinned, ok := configstore.MarkFailed(test.entries, test.pinned, test.version)
+
+		if rollback != test.target || pinned != test.wantPinned || ok != test.ok {
+			t.Fatalf("%s: rollback=%q pinned=%q ok=%t", test.name, rollback, pinned, ok)
+		}
+
+		if !test.ok {
+			continue
+		}
+
+		for _, entry := range test.entries {
+			if entry.Version == test.version && (!entry.Failed || entry.Acked || entry.Error != "rejected") {
+				t.Fatalf("%s: version must be failed %+v", test.name, entry)
+			}
+		}
+	}
+}
+
+func TestWithEndpoints(t *testing.T) {
+	t.Parallel()
+
+	pinned, err := utils.NewHashedSnapshot(map[string][]types.Resource{
+		resource.ClusterType:  {&cluster.Cluster{Name: "pinned"}},
+		resource.EndpointType: {&endpoint.ClusterLoadAssignment{ClusterName: "old"}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	live, err := utils.NewHashedSnapshot(map[string][]types.Resource{
+		resource.ClusterType:  {&cluster.Cluster{Name: "live"}},
+		resource.EndpointType: {&endpoint.ClusterLoadAssignment{ClusterName: "new"}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	snap, err := configstore.WithEndpoints(pinned, live)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, ok := snap.GetResources(resource.ClusterType)["pinned"]; !ok {
+		t.Fatal("clusters must be from pinned snapshot")
+	}
+
+	if _, ok := snap.GetResources(resource.EndpointType)["new"]; !ok {
+		t.Fatal("endpoints must be from live snapshot")
+	}
+
+	if len(snap.GetResources(resource.EndpointType)) != 1 {
+		t.Fatal("endpoints of pinned snapshot must be replaced")
+	}
+
+	if snap.GetVersion(resource.ClusterType) != pinned.GetVersion(resource.ClusterType) {
+		t.Fatal("version of clusters must not be changed")
+	}
+}
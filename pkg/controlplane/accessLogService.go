@@ -17,16 +17,16 @@ import (
 
 	alf "github.com/envoyproxy/go-control-plane/envoy/data/accesslog/v3"
 	accessloggrpc "github.com/envoyproxy/go-control-plane/envoy/service/accesslog/v3"
+	"github.com/maksim-paskal/envoy-control-plane/pkg/accesslog"
 	"github.com/pkg/errors"
-	log "github.com/sirupsen/logrus"
 )
 
-// AccessLogService buffers access logs from the remote Envoy nodes.
+// AccessLogService sends access logs from the remote Envoy nodes to access log sinks.
 type AccessLogService struct{}
 
 // StreamAccessLogs implements the access log service.
 func (svc *AccessLogService) StreamAccessLogs(stream accessloggrpc.AccessLogService_StreamAccessLogsServer) error {
-	var logName string
+	var logName, nodeID string
 
 	for {
 		msg, err := stream.Recv()
@@ -34,47 +34,44 @@ func (svc *AccessLogService) StreamAccessLogs(stream accessloggrpc.AccessLogServ
 			return errors.Wrap(err, "error in stream.Recv()")
 		}
 
+		// identifier is sent only in first message of stream
 		if msg.GetIdentifier() != nil {
 			logName = msg.GetIdentifier().GetLogName()
+			nodeID = msg.GetIdentifier().GetNode().GetId()
 		}
 
 		switch entries := msg.GetLogEntries().(type) {
 		case *accessloggrpc.StreamAccessLogsMessage_HttpLogs:
 			for _, entry := range entries.HttpLogs.GetLogEntry() {
 				if entry != nil {
-					common := entry.GetCommonProperties()
-					req := entry.GetRequest()
-					resp := entry.GetResponse()
-
-					if common == nil {
-						common = &alf.AccessLogCommon{}
-					}
-
-					if req == nil {
-						req = &alf.HTTPRequestProperties{}
-					}
-
-					if resp == nil {
-						resp = &alf.HTTPResponseProperties{}
-					}
-
-					log.Infof("[%s%s] %s %s %s %d %s %s",
-						logName, time.Now().Format(time.RFC3339), req.GetAuthority(), req.GetPath(), req.GetScheme(),
-						resp.GetResponseCode().GetValue(), req.GetRequestId(), common.GetUpstreamCluster())
+					accesslog.Push(&accesslog.Entry{
+						Time:    getEntryTime(entry.GetCommonProperties()),
+						LogName: logName,
+						NodeID:  nodeID,
+						HTTP:    entry,
+					})
 				}
 			}
 		case *accessloggrpc.StreamAccessLogsMessage_TcpLogs:
 			for _, entry := range entries.TcpLogs.GetLogEntry() {
 				if entry != nil {
-					common := entry.GetCommonProperties()
-					if common == nil {
-						common = &alf.AccessLogCommon{}
-					}
-
-					log.Infof("[%s%s] tcp %s %s",
-						logName, time.Now().Format(time.RFC3339), common.GetUpstreamLocalAddress(), common.GetUpstreamCluster())
+					accesslog.Push(&accesslog.Entry{
+						Time:    getEntryTime(entry.GetCommonProperties()),
+						LogName: logName,
+						NodeID:  nodeID,
+						TCP:     entry,
+					})
 				}
 			}
 		}
 	}
 }
+
+// start time of request, receive time if envoy did not send it.
+func getEntryTime(common *alf.AccessLogCommon) time.Time {
+	if startTime := common.GetStartTime(); startTime.IsValid() {
+		return startTime.AsTime()
+	}
+
+	return time.Now()
+}
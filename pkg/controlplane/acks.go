@@ -0,0 +1,362 @@
+/*
+Copyright paskal.maksim@gmail.com
+Licensed under the Apache License, Version 2.0 (the "License")
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package controlplane
+
+import (
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/envoyproxy/go-control-plane/pkg/cache/v3"
+	"github.com/maksim-paskal/envoy-control-plane/pkg/metrics"
+	"github.com/prometheus/client_golang/prometheus"
+	log "github.com/sirupsen/logrus"
+	"google.golang.org/protobuf/types/known/anypb"
+)
+
+const typeURLPrefix = "type.googleapis.com/"
+
+// ACK/NACK state of resource type in envoy stream.
+type AckState struct {
+	StreamID         int64
+	Delta            bool
+	NodeHash         string
+	LastSentVersion  string
+	LastAckedVersion string
+	// last response was rejected
+	Nacked          bool
+	LastNackVersion string     `json:",omitempty"`
+	LastNackError   string     `json:",omitempty"`
+	LastNackTime    *time.Time `json:",omitempty"`
+	Nacks           int
+	lastSentNonce   string
+	// resources of last response, names are resolved only on NACK
+	lastSentResources []*anypb.Any
+	lastSentNames     []string
+}
+
+var (
+	// envoy accepted response of snapshot with node hash
+	OnAck func(nodeHash string)
+	// envoy rejected response of snapshot with node hash
+	OnNack func(nodeHash, typeURL, nackError string)
+)
+
+func getMetricsType(typeURL string) string {
+	return strings.TrimPrefix(typeURL, typeURLPrefix)
+}
+
+func (stream *connectedStream) getAckState(streamID int64, delta bool, typeURL string) *AckState {
+	state, ok := stream.acks[typeURL]
+	if !ok {
+		state = &AckState{StreamID: streamID, Delta: delta}
+		stream.acks[typeURL] = state
+	}
+
+	state.NodeHash = GetNodeHash(stream.node)
+
+	return state
+}
+
+// save response that was sent to envoy stream.
+func (s *connectedStreams) onResponse(streamID int64, typeURL, version, nonce string, resources []*anypb.Any, names []string) { //nolint:lll
+	s.mutex.Lock()
+
+	stream, ok := s.streams[streamID]
+	if !ok || stream.node == nil {
+		s.mutex.Unlock()
+
+		return
+	}
+
+	state := stream.getAckState(streamID, s.delta, typeURL)
+
+	state.LastSentVersion = version
+	state.lastSentNonce = nonce
+	state.lastSentResources = resources
+	state.lastSentNames = names
+
+	nodeID := stream.node.GetId()
+
+	s.mutex.Unlock()
+
+	updateAckMetrics(nodeID, typeURL)
+}
+
+// process ACK or NACK of last response, version is empty for delta requests.
+func (s *connectedStreams) onRequest(streamID int64, typeURL, version, nonce string, isNack bool, nackError string) {
+	state, nodeID, ok := s.updateAckState(streamID, typeURL, version, nonce, isNack, nackError)
+	if !ok {
+		return
+	}
+
+	updateAckMetrics(nodeID, typeURL)
+
+	if !state.Nacked {
+		// hooks can push snapshots, stream must not wait for them
+		if OnAck != nil {
+			go OnAck(state.NodeHash)
+		}
+
+		return
+	}
+
+	metrics.XdsNack.WithLabelValues(nodeID, getMetricsType(typeURL)).Inc()
+
+	log.WithFields(log.Fields{
+		"node":      nodeID,
+		"nodeHash":  state.NodeHash,
+		"streamID":  streamID,
+		"type":      getMetricsType(typeURL),
+		"version":   state.LastNackVersion,
+		"acked":     state.LastAckedVersion,
+		"resources": strings.Join(state.lastSentNames, ","),
+	}).Warnf("envoy rejected config: %s", state.LastNackError)
+
+	if OnNack != nil {
+		go OnNack(state.NodeHash, typeURL, state.LastNackError)
+	}
+}
+
+func (s *connectedStreams) updateAckState(streamID int64, typeURL, version, nonce string, isNack bool, nackError string) (AckState, string, bool) { //nolint:lll
+	// first request of stream or resource type
+	if len(nonce) == 0 {
+		return AckState{}, "", false
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	stream, ok := s.streams[streamID]
+	if !ok || stream.node == nil {
+		return AckState{}, "", false
+	}
+
+	state := stream.getAckState(streamID, s.delta, typeURL)
+
+	// request for response that was sent before last response
+	if nonce != state.lastSentNonce {
+		return AckState{}, "", false
+	}
+
+	if !isNack {
+		if len(version) == 0 {
+			version = state.LastSentVersion
+		}
+
+		state.LastAckedVersion = version
+		state.Nacked = false
+
+		return *state, stream.node.GetId(), true
+	}
+
+	now := time.Now()
+
+	state.Nacks++
+	state.Nacked = true
+	state.LastNackVersion = state.LastSentVersion
+	state.LastNackError = nackError
+	state.LastNackTime = &now
+	state.lastSentNames = state.getSentNames()
+
+	return *state, stream.node.GetId(), true
+}
+
+func (s *AckState) getSentNames() []string {
+	if s.lastSentNames != nil {
+		return s.lastSentNames
+	}
+
+	return getResourceNames(s.lastSentResources)
+}
+
+// versions of streams with node id and resource type.
+type ackVersions struct {
+	sent   map[string]bool
+	acked  map[string]bool
+	nacked bool
+	found  bool
+}
+
+func (s *connectedStreams) addAckVersions(nodeID, typeURL string, versions *ackVersions) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	for _, stream := range s.streams {
+		if stream.node.GetId() != nodeID {
+			continue
+		}
+
+		state, ok := stream.acks[typeURL]
+		if !ok {
+			continue
+		}
+
+		versions.found = true
+		versions.sent[state.LastSentVersion] = true
+		versions.acked[state.LastAckedVersion] = true
+		versions.nacked = versions.nacked || state.Nacked
+	}
+}
+
+// version labels that were set by node id and type url.
+var ackMetricsLabels = struct {
+	mutex sync.Mutex
+	sent  map[string]map[string]bool
+	acked map[string]map[string]bool
+}{
+	sent:  make(map[string]map[string]bool),
+	acked: make(map[string]map[string]bool),
+}
+
+// set metrics of all streams of node id, labels of previous versions are deleted.
+func updateAckMetrics(nodeID, typeURL string) {
+	versions := &ackVersions{
+		sent:  make(map[string]bool),
+		acked: make(map[string]bool),
+	}
+
+	streams.addAckVersions(nodeID, typeURL, versions)
+	deltaStreams.addAckVersions(nodeID, typeURL, versions)
+
+	metricsType := getMetricsType(typeURL)
+	key := nodeID + "/" + typeURL
+
+	ackMetricsLabels.mutex.Lock()
+	defer ackMetricsLabels.mutex.Unlock()
+
+	updateVersionMetric(metrics.XdsSentVersion, nodeID, metricsType, ackMetricsLabels.sent[key], versions.sent)
+	updateVersionMetric(metrics.XdsAckedVersion, nodeID, metricsType, ackMetricsLabels.acked[key], versions.acked)
+
+	if !versions.found {
+		delete(ackMetricsLabels.sent, key)
+		delete(ackMetricsLabels.acked, key)
+
+		metrics.XdsNack.DeleteLabelValues(nodeID, metricsType)
+		metrics.XdsLastNack.DeleteLabelValues(nodeID, metricsType)
+
+		return
+	}
+
+	ackMetricsLabels.sent[key] = versions.sent
+	ackMetricsLabels.acked[key] = versions.acked
+
+	if versions.nacked {
+		metrics.XdsLastNack.WithLabelValues(nodeID, metricsType).Set(1)
+	} else {
+		metrics.XdsLastNack.WithLabelValues(nodeID, metricsType).Set(0)
+	}
+}
+
+func updateVersionMetric(gauge *prometheus.GaugeVec, nodeID, metricsType string, previous, current map[string]bool) {
+	for version := range previous {
+		if !current[version] {
+			gauge.DeleteLabelValues(nodeID, metricsType, version)
+		}
+	}
+
+	for version := range current {
+		if len(version) > 0 {
+			gauge.WithLabelValues(nodeID, metricsType, version).Set(1)
+		}
+	}
+}
+
+// remove metrics of closed stream.
+func deleteStreamAcks(nodeID string, stream *connectedStream) {
+	for typeURL := range stream.acks {
+		updateAckMetrics(nodeID, typeURL)
+	}
+}
+
+func (s *connectedStreams) addAckStates(nodeHash string, result map[string]map[string][]AckState) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	for _, stream := range s.streams {
+		if stream.node == nil || GetNodeHash(stream.node) != nodeHash {
+			continue
+		}
+
+		nodeID := stream.node.GetId()
+
+		for typeURL, state := range stream.acks {
+			if _, ok := result[nodeID]; !ok {
+				result[nodeID] = make(map[string][]AckState)
+			}
+
+			result[nodeID][typeURL] = append(result[nodeID][typeURL], *state)
+		}
+	}
+}
+
+// ACK/NACK states by node id, type url and stream of envoys that uses snapshot with node hash.
+func GetAckStates(nodeHash string) map[string]map[string][]AckState {
+	result := make(map[string]map[string][]AckState)
+
+	streams.addAckStates(nodeHash, result)
+	deltaStreams.addAckStates(nodeHash, result)
+
+	for _, types := range result {
+		for _, states := range types {
+			sort.Slice(states, func(i, j int) bool {
+				if states[i].Delta != states[j].Delta {
+					return !states[i].Delta
+				}
+
+				return states[i].StreamID < states[j].StreamID
+			})
+		}
+	}
+
+	return result
+}
+
+// returns false if some stream of node hash did not acknowledge subscribed resource type
+// or no stream is connected.
+func (s *connectedStreams) isAcked(nodeHash string, snap cache.ResourceSnapshot) (bool, bool) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	found := false
+
+	for _, stream := range s.streams {
+		if stream.node == nil || GetNodeHash(stream.node) != nodeHash {
+			continue
+		}
+
+		found = true
+
+		for typeURL := range stream.typeURLs {
+			state, ok := stream.acks[typeURL]
+			if !ok || state.Nacked || state.LastAckedVersion != snap.GetVersion(typeURL) {
+				return false, found
+			}
+		}
+	}
+
+	return true, found
+}
+
+// all connected envoys of node hash acknowledged every subscribed resource type of snapshot.
+func IsAcked(nodeHash string, snap cache.ResourceSnapshot) bool {
+	acked, found := streams.isAcked(nodeHash, snap)
+	if !acked {
+		return false
+	}
+
+	deltaAcked, deltaFound := deltaStreams.isAcked(nodeHash, snap)
+
+	return deltaAcked && (found || deltaFound)
+}
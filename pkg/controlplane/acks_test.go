@@ -0,0 +1,216 @@
+/*
+Copyright paskal.maksim@gmail.com
+Licensed under the Apache License, Version 2.0 (the "License")
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package controlplane_test
+
+import (
+	"context"
+	"testing"
+
+	core "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
+	discovery "github.com/envoyproxy/go-control-plane/envoy/service/discovery/v3"
+	"github.com/envoyproxy/go-control-plane/pkg/cache/types"
+	"github.com/envoyproxy/go-control-plane/pkg/cache/v3"
+	resource "github.com/envoyproxy/go-control-plane/pkg/resource/v3"
+	"github.com/maksim-paskal/envoy-control-plane/pkg/controlplane"
+	"google.golang.org/protobuf/encoding/protojson"
+)
+
+func newRequest(t *testing.T, nodeID, version, nonce, nackError string) *discovery.DiscoveryRequest {
+	t.Helper()
+
+	req := &discovery.DiscoveryRequest{
+		Node:          &core.Node{Id: nodeID},
+		TypeUrl:       resource.ClusterType,
+		VersionInfo:   version,
+		ResponseNonce: nonce,
+	}
+
+	if len(nackError) > 0 {
+		// error detail is google.rpc.Status
+		nack := &discovery.DiscoveryRequest{}
+
+		if err := protojson.Unmarshal([]byte(`{"errorDetail":{"message":"`+nackError+`"}}`), nack); err != nil {
+			t.Fatal(err)
+		}
+
+		req.ErrorDetail = nack.GetErrorDetail()
+	}
+
+	return req
+}
+
+func newResponse(version, nonce string) *discovery.DiscoveryResponse {
+	return &discovery.DiscoveryResponse{
+		TypeUrl:     resource.ClusterType,
+		VersionInfo: version,
+		Nonce:       nonce,
+	}
+}
+
+func getStreamState(t *testing.T, nodeID string, streamID int64) controlplane.AckState {
+	t.Helper()
+
+	for _, state := range controlplane.GetAckStates(nodeID)[nodeID][resource.ClusterType] {
+		if state.StreamID == streamID {
+			return state
+		}
+	}
+
+	t.Fatalf("stream %d has no state", streamID)
+
+	return controlplane.AckState{}
+}
+
+func TestAckNackSharedNodeID(t *testing.T) {
+	t.Parallel()
+
+	const (
+		nodeID  = "acks-test-id"
+		streamA = int64(1001)
+		streamB = int64(1002)
+	)
+
+	ctx := context.Background()
+	cb := &controlplane.Callbacks{}
+
+	for _, streamID := range []int64{streamA, streamB} {
+		if err := cb.OnStreamOpen(ctx, streamID, resource.AnyType); err != nil {
+			t.Fatal(err)
+		}
+
+		if err := cb.OnStreamRequest(streamID, newRequest(t, nodeID, "", "", "")); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// envoys with same node id receive responses with own nonces
+	cb.OnStreamResponse(ctx, streamA, nil, newResponse("v1", "a1"))
+	cb.OnStreamResponse(ctx, streamB, nil, newResponse("v1", "b1"))
+
+	tests := []struct {
+		name      string
+		streamID  int64
+		version   string
+		nonce     string
+		nackError string
+		acked     string
+		nacked    bool
+	}{
+		{name: "ack", streamID: streamA, version: "v1", nonce: "a1", acked: "v1"},
+		{name: "nack", streamID: streamB, version: "", nonce: "b1", nackError: "invalid cluster", nacked: true},
+		{name: "stale nonce", streamID: streamA, version: "", nonce: "b1", nackError: "stale", acked: "v1"},
+	}
+
+	for _, test := range tests {
+		if err := cb.OnStreamRequest(test.streamID, newRequest(t, nodeID, test.version, test.nonce, test.nackError)); err != nil {
+			t.Fatal(err)
+		}
+
+		state := getStreamState(t, nodeID, test.streamID)
+
+		if state.LastAckedVersion != test.acked || state.Nacked != test.nacked {
+			t.Fatalf("%s: not correct state %+v", test.name, state)
+		}
+	}
+
+	if state := getStreamState(t, nodeID, streamB); state.LastNackError != "invalid cluster" || state.LastNackVersion != "v1" {
+		t.Fatalf("not correct NACK %+v", state)
+	}
+
+	// response before last response
+	cb.OnStreamResponse(ctx, streamA, nil, newResponse("v2", "a2"))
+
+	if err := cb.OnStreamRequest(streamA, newRequest(t, nodeID, "v1", "a1", "")); err != nil {
+		t.Fatal(err)
+	}
+
+	if state := getStreamState(t, nodeID, streamA); state.LastAckedVersion != "v1" || state.LastSentVersion != "v2" {
+		t.Fatalf("stale ACK must be ignored %+v", state)
+	}
+
+	cb.OnStreamClosed(streamA, &core.Node{Id: nodeID})
+	cb.OnStreamClosed(streamB, &core.Node{Id: nodeID})
+
+	if states := controlplane.GetAckStates(nodeID); len(states) != 0 {
+		t.Fatalf("states of closed streams must be deleted %+v", states)
+	}
+}
+
+func TestIsAcked(t *testing.T) {
+	t.Parallel()
+
+	const (
+		nodeID  = "is-acked-test-id"
+		streamA = int64(2001)
+		streamB = int64(2002)
+	)
+
+	ctx := context.Background()
+	cb := &controlplane.Callbacks{}
+
+	snap, err := cache.NewSnapshot("v1", map[resource.Type][]types.Resource{
+		resource.ClusterType:  {},
+		resource.ListenerType: {},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if controlplane.IsAcked(nodeID, snap) {
+		t.Fatal("node without streams must not be acked")
+	}
+
+	for _, streamID := range []int64{streamA, streamB} {
+		if err := cb.OnStreamOpen(ctx, streamID, resource.AnyType); err != nil {
+			t.Fatal(err)
+		}
+
+		if err := cb.OnStreamRequest(streamID, newRequest(t, nodeID, "", "", "")); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	cb.OnStreamResponse(ctx, streamA, nil, newResponse("v1", "a1"))
+	cb.OnStreamResponse(ctx, streamB, nil, newResponse("v1", "b1"))
+
+	if err := cb.OnStreamRequest(streamA, newRequest(t, nodeID, "v1", "a1", "")); err != nil {
+		t.Fatal(err)
+	}
+
+	if controlplane.IsAcked(nodeID, snap) {
+		t.Fatal("stream without ACK must not be acked")
+	}
+
+	if err := cb.OnStreamRequest(streamB, newRequest(t, nodeID, "v1", "b1", "")); err != nil {
+		t.Fatal(err)
+	}
+
+	if !controlplane.IsAcked(nodeID, snap) {
+		t.Fatal("all streams must be acked")
+	}
+
+	// new subscription without response
+	listeners := newRequest(t, nodeID, "", "", "")
+	listeners.TypeUrl = resource.ListenerType
+
+	if err := cb.OnStreamRequest(streamB, listeners); err != nil {
+		t.Fatal(err)
+	}
+
+	if controlplane.IsAcked(nodeID, snap) {
+		t.Fatal("subscribed type without ACK must not be acked")
+	}
+
+	cb.OnStreamClosed(streamA, &core.Node{Id: nodeID})
+	cb.OnStreamClosed(streamB, &core.Node{Id: nodeID})
+}
@@ -0,0 +1,259 @@
+/*
+Copyright paskal.maksim@gmail.com
+Licensed under the Apache License, Version 2.0 (the "License")
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package controlplane
+
+import (
+	"context"
+	"encoding/json"
+	"path/filepath"
+	"time"
+
+	discovery "github.com/envoyproxy/go-control-plane/envoy/service/discovery/v3"
+	"github.com/envoyproxy/go-control-plane/pkg/cache/types"
+	"github.com/envoyproxy/go-control-plane/pkg/cache/v3"
+	"github.com/maksim-paskal/envoy-control-plane/pkg/config"
+	"github.com/maksim-paskal/envoy-control-plane/pkg/utils"
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/types/known/anypb"
+)
+
+const (
+	auditFileName = "xds-audit.log"
+	megabyte      = 1024 * 1024
+
+	auditRequest  = "request"
+	auditResponse = "response"
+
+	auditStreamSotw  = "sotw"
+	auditStreamDelta = "delta"
+	auditStreamFetch = "fetch"
+
+	auditACK  = "ACK"
+	auditNACK = "NACK"
+)
+
+// one line of xDS audit log.
+type auditRecord struct {
+	Time      time.Time `json:"time"`
+	Direction string    `json:"direction"`
+	Stream    string    `json:"stream"`
+	StreamID  int64     `json:"streamId,omitempty"`
+	NodeID    string    `json:"nodeId"`
+	TypeURL   string    `json:"typeUrl"`
+	Version   string    `json:"version,omitempty"`
+	Nonce     string    `json:"nonce,omitempty"`
+	// ACK or NACK of previous response, empty for first request
+	Status           string            `json:"status,omitempty"`
+	Error            string            `json:"error,omitempty"`
+	ResourceNames    []string          `json:"resourceNames,omitempty"`
+	RemovedResources []string          `json:"removedResources,omitempty"`
+	Resources        []json.RawMessage `json:"resources,omitempty"`
+}
+
+var auditLog *utils.RotatingFile
+
+func initAudit(ctx context.Context) error {
+	if !*config.Get().LogAccess {
+		return nil
+	}
+
+	file, err := utils.NewRotatingFile(
+		filepath.Join(*config.Get().LogPath, auditFileName),
+		int64(*config.Get().LogAccessMaxSize)*megabyte,
+		*config.Get().LogAccessBackups,
+		*config.Get().LogAccessMaxAge,
+	)
+	if err != nil {
+		return errors.Wrap(err, "error creating xDS audit log")
+	}
+
+	auditLog = file
+
+	go func() {
+		<-ctx.Done()
+
+		if err := file.Close(); err != nil {
+			log.WithError(err).Warn("error closing xDS audit log")
+		}
+	}()
+
+	return nil
+}
+
+func writeAudit(record *auditRecord) {
+	if auditLog == nil {
+		return
+	}
+
+	record.Time = time.Now()
+
+	b, err := json.Marshal(record)
+	if err != nil {
+		log.WithError(err).Warn("error in json.Marshal")
+
+		return
+	}
+
+	if _, err := auditLog.Write(append(b, '\n')); err != nil {
+		log.WithError(err).Warn("error writing xDS audit log")
+	}
+}
+
+func getAckStatus(nonce string, isNack bool, nackError string) (string, string) {
+	if len(nonce) == 0 {
+		return "", ""
+	}
+
+	if isNack {
+		return auditNACK, nackError
+	}
+
+	return auditACK, ""
+}
+
+func auditDiscoveryRequest(stream string, streamID int64, req *discovery.DiscoveryRequest) {
+	if auditLog == nil {
+		return
+	}
+
+	status, nackError := getAckStatus(req.GetResponseNonce(), req.GetErrorDetail() != nil, req.GetErrorDetail().GetMessage())
+
+	writeAudit(&auditRecord{
+		Direction:     auditRequest,
+		Stream:        stream,
+		StreamID:      streamID,
+		NodeID:        req.GetNode().GetId(),
+		TypeURL:       req.GetTypeUrl(),
+		Version:       req.GetVersionInfo(),
+		Nonce:         req.GetResponseNonce(),
+		Status:        status,
+		Error:         nackError,
+		ResourceNames: req.GetResourceNames(),
+	})
+}
+
+func auditDiscoveryResponse(stream string, streamID int64, nodeID string, resp *discovery.DiscoveryResponse) {
+	if auditLog == nil {
+		return
+	}
+
+	writeAudit(&auditRecord{
+		Direction:     auditResponse,
+		Stream:        stream,
+		StreamID:      streamID,
+		NodeID:        nodeID,
+		TypeURL:       resp.GetTypeUrl(),
+		Version:       resp.GetVersionInfo(),
+		Nonce:         resp.GetNonce(),
+		ResourceNames: getResourceNames(resp.GetResources()),
+		Resources:     getAuditResources(resp.GetResources()),
+	})
+}
+
+func auditDeltaDiscoveryRequest(streamID int64, nodeID string, req *discovery.DeltaDiscoveryRequest) {
+	if auditLog == nil {
+		return
+	}
+
+	status, nackError := getAckStatus(req.GetResponseNonce(), req.GetErrorDetail() != nil, req.GetErrorDetail().GetMessage())
+
+	writeAudit(&auditRecord{
+		Direction:        auditRequest,
+		Stream:           auditStreamDelta,
+		StreamID:         streamID,
+		NodeID:           nodeID,
+		TypeURL:          req.GetTypeUrl(),
+		Nonce:            req.GetResponseNonce(),
+		Status:           status,
+		Error:            nackError,
+		ResourceNames:    req.GetResourceNamesSubscribe(),
+		RemovedResources: req.GetResourceNamesUnsubscribe(),
+	})
+}
+
+func auditDeltaDiscoveryResponse(streamID int64, nodeID string, resp *discovery.DeltaDiscoveryResponse) {
+	if auditLog == nil {
+		return
+	}
+
+	names := make([]string, 0, len(resp.GetResources()))
+	resources := make([]*anypb.Any, 0, len(resp.GetResources()))
+
+	for _, item := range resp.GetResources() {
+		names = append(names, item.GetName())
+		resources = append(resources, item.GetResource())
+	}
+
+	writeAudit(&auditRecord{
+		Direction:        auditResponse,
+		Stream:           auditStreamDelta,
+		StreamID:         streamID,
+		NodeID:           nodeID,
+		TypeURL:          resp.GetTypeUrl(),
+		Version:          resp.GetSystemVersionInfo(),
+		Nonce:            resp.GetNonce(),
+		ResourceNames:    names,
+		RemovedResources: resp.GetRemovedResources(),
+		Resources:        getAuditResources(resources),
+	})
+}
+
+// resources payload if enabled, private keys and inline secrets are masked.
+func getAuditResources(resources []*anypb.Any) []json.RawMessage {
+	if !*config.Get().LogAccessResources || len(resources) == 0 {
+		return nil
+	}
+
+	result := make([]json.RawMessage, 0, len(resources))
+
+	for _, item := range resources {
+		b, err := protojson.Marshal(item)
+		if err != nil {
+			log.WithError(err).Debugf("error marshaling %s", item.GetTypeUrl())
+
+			continue
+		}
+
+		if b, err = utils.RedactJSONBytes(b); err != nil {
+			log.WithError(err).Debugf("error redacting %s", item.GetTypeUrl())
+
+			continue
+		}
+
+		result = append(result, b)
+	}
+
+	return result
+}
+
+// names of resources, type url if resource can not be resolved.
+func getResourceNames(resources []*anypb.Any) []string {
+	names := make([]string, 0, len(resources))
+
+	for _, item := range resources {
+		message, err := item.UnmarshalNew()
+		if err != nil {
+			names = append(names, item.GetTypeUrl())
+
+			continue
+		}
+
+		if r, ok := message.(types.Resource); ok {
+			names = append(names, cache.GetResourceName(r))
+		}
+	}
+
+	return names
+}
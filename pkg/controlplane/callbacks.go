@@ -14,9 +14,6 @@ package controlplane
 
 import (
 	"context"
-	"fmt"
-	"os"
-	"path"
 	"sync"
 
 	core "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
@@ -24,11 +21,8 @@ import (
 	"github.com/maksim-paskal/envoy-control-plane/pkg/config"
 	"github.com/maksim-paskal/envoy-control-plane/pkg/metrics"
 	log "github.com/sirupsen/logrus"
-	"google.golang.org/protobuf/encoding/protojson"
 )
 
-const filePerm = 0o644
-
 type callbacks struct {
 	signal   chan struct{}
 	fetches  int
@@ -42,9 +36,11 @@ func (cb *callbacks) Report() {
 	}
 }
 
-func (cb *callbacks) OnStreamOpen(_ context.Context, streamID int64, typ string) error {
+func (cb *callbacks) OnStreamOpen(ctx context.Context, streamID int64, typ string) error {
 	metrics.GrpcOnStreamOpen.Inc()
 
+	streams.open(ctx, streamID)
+
 	if *config.Get().LogAccess {
 		log.WithField("streamID", streamID).Infof("OnStreamOpen==>%s", typ)
 	}
@@ -55,6 +51,8 @@ func (cb *callbacks) OnStreamOpen(_ context.Context, streamID int64, typ string)
 func (cb *callbacks) OnStreamClosed(streamID int64, node *core.Node) {
 	metrics.GrpcOnStreamClosed.Inc()
 
+	streams.close(streamID)
+
 	if *config.Get().LogAccess {
 		log.WithFields(log.Fields{
 			"streamID": streamID,
@@ -63,12 +61,14 @@ func (cb *callbacks) OnStreamClosed(streamID int64, node *core.Node) {
 	}
 }
 
-func (cb *callbacks) OnStreamRequest(streamID int64, _ *discovery.DiscoveryRequest) error {
+func (cb *callbacks) OnStreamRequest(streamID int64, req *discovery.DiscoveryRequest) error {
 	metrics.GrpcOnStreamRequest.Inc()
 
-	if *config.Get().LogAccess {
-		log.WithField("streamID", streamID).Info("OnStreamRequest")
-	}
+	streams.request(streamID, req.GetNode(), req.GetTypeUrl())
+
+	streams.onRequest(streamID, req.GetTypeUrl(), req.GetVersionInfo(), req.GetResponseNonce(), req.GetErrorDetail() != nil, req.GetErrorDetail().GetMessage()) //nolint:lll
+
+	auditDiscoveryRequest(auditStreamSotw, streamID, req)
 
 	cb.mutex.Lock()
 	defer cb.mutex.Unlock()
@@ -83,42 +83,12 @@ func (cb *callbacks) OnStreamRequest(streamID int64, _ *discovery.DiscoveryReque
 	return nil
 }
 
-func (cb *callbacks) OnStreamResponse(_ context.Context, _ int64, r *discovery.DiscoveryRequest, w *discovery.DiscoveryResponse) { //nolint:lll
+func (cb *callbacks) OnStreamResponse(_ context.Context, streamID int64, _ *discovery.DiscoveryRequest, w *discovery.DiscoveryResponse) { //nolint:lll
 	metrics.GrpcOnStreamResponse.Inc()
 
-	if *config.Get().LogAccess {
-		discoveryRequest, _ := protojson.Marshal(r)
-		discoveryResponse, _ := protojson.Marshal(w)
-
-		fileNameResponce := path.Join(*config.Get().LogPath, fmt.Sprintf("OnStreamResponse.%s.log", r.GetNode().GetId()))
-
-		fResponce, err := os.OpenFile(fileNameResponce, os.O_APPEND|os.O_CREATE|os.O_WRONLY, filePerm)
-		if err != nil {
-			log.Println(err)
-		}
+	streams.onResponse(streamID, w.GetTypeUrl(), w.GetVersionInfo(), w.GetNonce(), w.GetResources(), nil)
 
-		defer fResponce.Close()
-
-		fileNameRequest := path.Join(*config.Get().LogPath, fmt.Sprintf("OnStreamRequest.%s.log", r.GetNode().GetId()))
-
-		fRequest, err := os.OpenFile(fileNameRequest, os.O_APPEND|os.O_CREATE|os.O_WRONLY, filePerm)
-		if err != nil {
-			log.Println(err)
-		}
-
-		defer fResponce.Close()
-
-		discoveryRequestText := fmt.Sprintf("\nDiscoveryRequest=>\n\n%s\n\n", string(discoveryRequest))
-		discoveryResponseText := fmt.Sprintf("\nDiscoveryResponse=>\n\n%s\n\n", string(discoveryResponse))
-
-		if _, err := fRequest.WriteString(discoveryRequestText); err != nil {
-			log.Println(err)
-		}
-
-		if _, err := fResponce.WriteString(discoveryResponseText); err != nil {
-			log.Println(err)
-		}
-	}
+	auditDiscoveryResponse(auditStreamSotw, streamID, streams.getNode(streamID).GetId(), w)
 
 	cb.Report()
 }
@@ -126,11 +96,7 @@ func (cb *callbacks) OnStreamResponse(_ context.Context, _ int64, r *discovery.D
 func (cb *callbacks) OnFetchRequest(_ context.Context, req *discovery.DiscoveryRequest) error {
 	metrics.GrpcOnFetchRequest.Inc()
 
-	if *config.Get().LogAccess {
-		log := log.WithField("node", req.GetNode().GetId())
-
-		log.Info("OnFetchRequest")
-	}
+	auditDiscoveryRequest(auditStreamFetch, 0, req)
 
 	cb.mutex.Lock()
 	defer cb.mutex.Unlock()
@@ -148,25 +114,17 @@ func (cb *callbacks) OnFetchRequest(_ context.Context, req *discovery.DiscoveryR
 func (cb *callbacks) OnFetchResponse(r *discovery.DiscoveryRequest, w *discovery.DiscoveryResponse) {
 	metrics.GrpcOnFetchResponse.Inc()
 
-	if *config.Get().LogAccess {
-		log := log.WithField("node", r.GetNode().GetId())
-
-		discoveryRequest, _ := protojson.Marshal(r)
-		discoveryResponse, _ := protojson.Marshal(w)
-
-		log.Infof("DiscoveryRequest=>%s\nDiscoveryResponse=>%s\n", string(discoveryRequest), string(discoveryResponse)) //nolint:lll
-	}
+	auditDiscoveryResponse(auditStreamFetch, 0, r.GetNode().GetId(), w)
 }
 
 func (cb *callbacks) OnStreamDeltaRequest(streamID int64, req *discovery.DeltaDiscoveryRequest) error {
 	metrics.GrpcOnStreamDeltaRequest.Inc()
 
-	if *config.Get().LogAccess {
-		log := log.WithField("streamID", streamID)
+	deltaStreams.request(streamID, req.GetNode(), req.GetTypeUrl())
 
-		json, _ := protojson.Marshal(req)
-		log.Infof("DeltaDiscoveryRequest=>\n%s\n", string(json))
-	}
+	deltaStreams.onRequest(streamID, req.GetTypeUrl(), "", req.GetResponseNonce(), req.GetErrorDetail() != nil, req.GetErrorDetail().GetMessage()) //nolint:lll
+
+	auditDeltaDiscoveryRequest(streamID, deltaStreams.getNode(streamID).GetId(), req)
 
 	return nil
 }
@@ -174,32 +132,30 @@ func (cb *callbacks) OnStreamDeltaRequest(streamID int64, req *discovery.DeltaDi
 func (cb *callbacks) OnStreamDeltaResponse(streamID int64, req *discovery.DeltaDiscoveryRequest, resp *discovery.DeltaDiscoveryResponse) { //nolint:lll
 	metrics.GrpcOnStreamDeltaResponse.Inc()
 
-	if *config.Get().LogAccess {
-		log := log.WithField("streamID", streamID)
+	names := make([]string, 0, len(resp.GetResources()))
 
-		deltaDiscoveryRequest, _ := protojson.Marshal(req)
-		deltaDiscoveryResponse, _ := protojson.Marshal(resp)
-
-		log.Infof("DeltaDiscoveryRequest=>%s\nDeltaDiscoveryResponse=>%s\n", string(deltaDiscoveryRequest), string(deltaDiscoveryResponse)) //nolint:lll
+	for _, resource := range resp.GetResources() {
+		names = append(names, resource.GetName())
 	}
+
+	deltaStreams.onResponse(streamID, resp.GetTypeUrl(), resp.GetSystemVersionInfo(), resp.GetNonce(), nil, names)
+
+	auditDeltaDiscoveryResponse(streamID, deltaStreams.getNode(streamID).GetId(), resp)
 }
 
 func (cb *callbacks) OnStreamDeltaRequestOnStreamDeltaRequest(streamID int64, req *discovery.DeltaDiscoveryRequest) error { //nolint: lll
 	metrics.GrpcOnStreamDeltaRequestOnStreamDeltaRequest.Inc()
 
-	if *config.Get().LogAccess {
-		log := log.WithField("streamID", streamID)
-
-		json, _ := protojson.Marshal(req)
-		log.Infof("DeltaDiscoveryRequest=>\n%s\n", string(json))
-	}
+	auditDeltaDiscoveryRequest(streamID, deltaStreams.getNode(streamID).GetId(), req)
 
 	return nil
 }
 
-func (cb *callbacks) OnDeltaStreamOpen(_ context.Context, streamID int64, typeURL string) error {
+func (cb *callbacks) OnDeltaStreamOpen(ctx context.Context, streamID int64, typeURL string) error {
 	metrics.GrpcOnDeltaStreamOpen.Inc()
 
+	deltaStreams.open(ctx, streamID)
+
 	if *config.Get().LogAccess {
 		log := log.WithField("streamID", streamID)
 
@@ -212,6 +168,8 @@ func (cb *callbacks) OnDeltaStreamOpen(_ context.Context, streamID int64, typeUR
 func (cb *callbacks) OnDeltaStreamClosed(streamID int64, node *core.Node) {
 	metrics.GrpcOnDeltaStreamClosed.Inc()
 
+	deltaStreams.close(streamID)
+
 	if *config.Get().LogAccess {
 		log.WithFields(log.Fields{
 			"streamID": streamID,
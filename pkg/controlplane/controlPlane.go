@@ -17,10 +17,12 @@ import (
 	"crypto/tls"
 	"crypto/x509"
 	"net"
+	"sync"
 	"time"
 
 	accesslog "github.com/envoyproxy/go-control-plane/envoy/service/accesslog/v3"
 	clusterservice "github.com/envoyproxy/go-control-plane/envoy/service/cluster/v3"
+	discoverygrpc "github.com/envoyproxy/go-control-plane/envoy/service/discovery/v3"
 	endpointservice "github.com/envoyproxy/go-control-plane/envoy/service/endpoint/v3"
 	listenerservice "github.com/envoyproxy/go-control-plane/envoy/service/listener/v3"
 	routeservice "github.com/envoyproxy/go-control-plane/envoy/service/route/v3"
@@ -42,9 +44,12 @@ const (
 	grpcMaxConcurrentStreams = 1000000
 )
 
-var SnapshotCache cache.SnapshotCache = cache.NewSnapshotCache(false, cache.IDHash{}, &Logger{})
+var SnapshotCache cache.SnapshotCache = cache.NewSnapshotCache(false, nodeHash{}, &Logger{})
 
-var grpcServer *grpc.Server
+var (
+	xdsServer  xds.Server
+	startMutex sync.Mutex
+)
 
 func Init(ctx context.Context) {
 	signal := make(chan struct{})
@@ -54,21 +59,32 @@ func Init(ctx context.Context) {
 		requests: 0,
 	}
 
-	createGrpcServer()
+	xdsServer = xds.NewServer(ctx, SnapshotCache, cb)
 
-	als := &AccessLogService{}
+	if err := initAudit(ctx); err != nil {
+		log.WithError(err).Fatal()
+	}
+}
 
-	server := xds.NewServer(ctx, SnapshotCache, cb)
+// grpc server can not be started after stop, new server created for every start.
+func newGrpcServer() *grpc.Server {
+	grpcServer := createGrpcServer()
+
+	als := &AccessLogService{}
 
 	accesslog.RegisterAccessLogServiceServer(grpcServer, als)
-	endpointservice.RegisterEndpointDiscoveryServiceServer(grpcServer, server)
-	clusterservice.RegisterClusterDiscoveryServiceServer(grpcServer, server)
-	routeservice.RegisterRouteDiscoveryServiceServer(grpcServer, server)
-	listenerservice.RegisterListenerDiscoveryServiceServer(grpcServer, server)
-	secretservice.RegisterSecretDiscoveryServiceServer(grpcServer, server)
+	// all discovery services also serve incremental (delta) xDS
+	discoverygrpc.RegisterAggregatedDiscoveryServiceServer(grpcServer, xdsServer)
+	endpointservice.RegisterEndpointDiscoveryServiceServer(grpcServer, xdsServer)
+	clusterservice.RegisterClusterDiscoveryServiceServer(grpcServer, xdsServer)
+	routeservice.RegisterRouteDiscoveryServiceServer(grpcServer, xdsServer)
+	listenerservice.RegisterListenerDiscoveryServiceServer(grpcServer, xdsServer)
+	secretservice.RegisterSecretDiscoveryServiceServer(grpcServer, xdsServer)
+
+	return grpcServer
 }
 
-func createGrpcServer() {
+func createGrpcServer() *grpc.Server {
 	serverCert, _, serverKey, _, err := certs.NewCertificate([]string{config.AppName}, certs.CertValidityMax)
 	if err != nil {
 		log.WithError(err).Fatal()
@@ -103,10 +119,15 @@ func createGrpcServer() {
 		}),
 	)
 
-	grpcServer = grpc.NewServer(grpcOptions...)
+	return grpc.NewServer(grpcOptions...)
 }
 
+// serve xDS until context is done, after that streams are drained.
 func Start(ctx context.Context) {
+	// previous server must be drained before new server listen on the same address
+	startMutex.Lock()
+	defer startMutex.Unlock()
+
 	log.Info("grpc.address=", *config.Get().GrpcAddress)
 
 	lis, err := net.Listen("tcp", *config.Get().GrpcAddress)
@@ -114,13 +135,40 @@ func Start(ctx context.Context) {
 		log.WithError(err).Fatal()
 	}
 
+	grpcServer := newGrpcServer()
+	drained := make(chan struct{})
+
 	go func() {
 		<-ctx.Done()
 
-		grpcServer.GracefulStop()
+		drain(grpcServer)
+		close(drained)
 	}()
 
 	if err := grpcServer.Serve(lis); err != nil {
 		log.WithError(err).Fatal()
 	}
+
+	<-drained
+
+	log.Info("grpc server stopped")
+}
+
+// stop accepting new streams and send GOAWAY to clients,
+// xDS streams never ends - they are closed after grace period.
+func drain(grpcServer *grpc.Server) {
+	log.Infof("draining grpc server for %s", *config.Get().GracePeriod)
+
+	stopped := make(chan struct{})
+
+	go func() {
+		grpcServer.GracefulStop()
+		close(stopped)
+	}()
+
+	select {
+	case <-stopped:
+	case <-time.After(*config.Get().GracePeriod):
+		grpcServer.Stop()
+	}
 }
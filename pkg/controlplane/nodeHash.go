@@ -0,0 +1,195 @@
+/*
+Copyright paskal.maksim@gmail.com
+Licensed under the Apache License, Version 2.0 (the "License")
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package controlplane
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"regexp"
+	"strings"
+	"sync"
+	"unicode"
+	"unicode/utf8"
+
+	core "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
+	"github.com/maksim-paskal/envoy-control-plane/pkg/config"
+	log "github.com/sirupsen/logrus"
+	"google.golang.org/protobuf/proto"
+)
+
+const nodeDataHashLength = 16
+
+var (
+	// node ids which configs rendered for every connected envoy.
+	perNodeIDs = new(sync.Map)
+
+	// ids of all loaded configs.
+	configIDs = new(sync.Map)
+
+	nodeHashRegex     *regexp.Regexp
+	nodeHashRegexOnce sync.Once
+
+	// called when envoy with per node config sends first request.
+	OnNewNode func(node *core.Node)
+	// called when last stream of envoy with per node config closed.
+	OnNodeDisconnected func(node *core.Node)
+)
+
+// snapshot of per node configs are stored with node data hash.
+type nodeHash struct{}
+
+func (nodeHash) ID(node *core.Node) string {
+	return GetNodeHash(node)
+}
+
+func SetPerNode(nodeID string, enabled bool) {
+	if enabled {
+		perNodeIDs.Store(nodeID, true)
+	} else {
+		perNodeIDs.Delete(nodeID)
+	}
+}
+
+func isPerNode(nodeID string) bool {
+	_, ok := perNodeIDs.Load(nodeID)
+
+	return ok
+}
+
+func RegisterConfigID(configID string) {
+	configIDs.Store(configID, true)
+}
+
+func UnregisterConfigID(configID string) {
+	configIDs.Delete(configID)
+}
+
+// key of node in snapshot cache.
+func GetNodeHash(node *core.Node) string {
+	if node == nil {
+		return ""
+	}
+
+	configID := ResolveConfigID(node)
+
+	if !isPerNode(configID) {
+		return configID
+	}
+
+	return configID + "/" + getNodeDataHash(node)
+}
+
+// config id of envoy node, depends on -node.hash strategy.
+func ResolveConfigID(node *core.Node) string {
+	var regex *regexp.Regexp
+
+	if *config.Get().NodeHash == config.NodeHashRegex {
+		regex = getNodeHashRegex()
+	}
+
+	return resolveConfigID(node, *config.Get().NodeHash, regex, *config.Get().NodeHashMetadata)
+}
+
+func resolveConfigID(node *core.Node, nodeHash string, regex *regexp.Regexp, metadataKey string) string {
+	nodeID := node.GetId()
+
+	switch nodeHash {
+	case config.NodeHashPrefix:
+		return findConfigID(nodeID, "")
+	case config.NodeHashRegex:
+		if match := regex.FindStringSubmatch(nodeID); len(match) > 1 && len(match[1]) > 0 {
+			return findConfigID(match[1], "-")
+		}
+	case config.NodeHashMetadata:
+		if value := node.GetMetadata().GetFields()[metadataKey].GetStringValue(); len(value) > 0 {
+			return findConfigID(value, "-")
+		}
+	}
+
+	return nodeID
+}
+
+func getNodeHashRegex() *regexp.Regexp {
+	nodeHashRegexOnce.Do(func() {
+		nodeHashRegex = regexp.MustCompile(*config.Get().NodeHashRegex)
+	})
+
+	return nodeHashRegex
+}
+
+// loaded config with the same id or with longest id that is prefix of id,
+// canary or version suffix after separator will resolve to main config.
+func findConfigID(id, separator string) string {
+	if _, ok := configIDs.Load(id); ok {
+		return id
+	}
+
+	result := ""
+
+	configIDs.Range(func(key, _ interface{}) bool {
+		configID, ok := key.(string)
+		if !ok {
+			return true
+		}
+
+		if hasConfigIDPrefix(id, configID, separator) && len(configID) > len(result) {
+			result = configID
+		}
+
+		return true
+	})
+
+	if len(result) == 0 {
+		return id
+	}
+
+	return result
+}
+
+// without separator config id must end on word boundary of id, test1 is not prefix of test10-id.
+func hasConfigIDPrefix(id, configID, separator string) bool {
+	if !strings.HasPrefix(id, configID+separator) {
+		return false
+	}
+
+	if len(separator) > 0 || len(id) == len(configID) {
+		return true
+	}
+
+	next, _ := utf8.DecodeRuneInString(id[len(configID):])
+
+	return !unicode.IsLetter(next) && !unicode.IsDigit(next)
+}
+
+// hash of node fields that can be used in config templates.
+func getNodeDataHash(node *core.Node) string {
+	nodeData := &core.Node{
+		Id:                   node.GetId(),
+		Cluster:              node.GetCluster(),
+		Locality:             node.GetLocality(),
+		Metadata:             node.GetMetadata(),
+		UserAgentName:        node.GetUserAgentName(),
+		UserAgentVersionType: node.GetUserAgentVersionType(),
+	}
+
+	b, err := proto.MarshalOptions{Deterministic: true}.Marshal(nodeData)
+	if err != nil {
+		log.WithError(err).Error("error marshaling node")
+
+		return ""
+	}
+
+	hash := sha256.Sum256(b)
+
+	return hex.EncodeToString(hash[:])[:nodeDataHashLength]
+}
@@ -0,0 +1,91 @@
+/*
+Copyright paskal.maksim@gmail.com
+Licensed under the Apache License, Version 2.0 (the "License")
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package controlplane_test
+
+import (
+	"regexp"
+	"testing"
+
+	core "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
+	"github.com/maksim-paskal/envoy-control-plane/pkg/config"
+	"github.com/maksim-paskal/envoy-control-plane/pkg/controlplane"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+func TestFindConfigID(t *testing.T) {
+	t.Parallel()
+
+	for _, configID := range []string{"find-test1", "find-test1-id", "find-test10"} {
+		controlplane.RegisterConfigID(configID)
+	}
+
+	tests := []struct {
+		id        string
+		separator string
+		result    string
+	}{
+		{id: "find-test1", result: "find-test1"},
+		{id: "find-test1-id-7d9f", result: "find-test1-id"},
+		{id: "find-test1.pod", result: "find-test1"},
+		{id: "find-test10-id", result: "find-test10"},
+		{id: "find-test100", result: "find-test100"},
+		{id: "find-test1-canary", separator: "-", result: "find-test1"},
+		{id: "find-test1canary", separator: "-", result: "find-test1canary"},
+		{id: "find-unknown", result: "find-unknown"},
+	}
+
+	for _, test := range tests {
+		if result := controlplane.FindConfigID(test.id, test.separator); result != test.result {
+			t.Fatalf("%s: got %s, want %s", test.id, result, test.result)
+		}
+	}
+}
+
+func TestResolveConfigID(t *testing.T) {
+	t.Parallel()
+
+	for _, configID := range []string{"resolve-test1", "resolve-test10"} {
+		controlplane.RegisterConfigID(configID)
+	}
+
+	regex := regexp.MustCompile(`^(.+)-[a-z0-9]+-[a-z0-9]+$`)
+
+	metadata := func(value string) *structpb.Struct {
+		return &structpb.Struct{Fields: map[string]*structpb.Value{
+			"config": structpb.NewStringValue(value),
+		}}
+	}
+
+	tests := []struct {
+		name     string
+		node     *core.Node
+		nodeHash string
+		result   string
+	}{
+		{name: "id", node: &core.Node{Id: "resolve-test1-pod"}, nodeHash: config.NodeHashID, result: "resolve-test1-pod"},
+		{name: "prefix", node: &core.Node{Id: "resolve-test1-pod"}, nodeHash: config.NodeHashPrefix, result: "resolve-test1"},
+		{name: "prefix boundary", node: &core.Node{Id: "resolve-test10-pod"}, nodeHash: config.NodeHashPrefix, result: "resolve-test10"},  //nolint:lll
+		{name: "prefix unknown", node: &core.Node{Id: "resolve-test2-pod"}, nodeHash: config.NodeHashPrefix, result: "resolve-test2-pod"}, //nolint:lll
+		{name: "regex", node: &core.Node{Id: "resolve-test1-7d9f-x2k"}, nodeHash: config.NodeHashRegex, result: "resolve-test1"},
+		{name: "regex canary", node: &core.Node{Id: "resolve-test1-canary-7d9f-x2k"}, nodeHash: config.NodeHashRegex, result: "resolve-test1"}, //nolint:lll
+		{name: "regex not match", node: &core.Node{Id: "resolve-test1"}, nodeHash: config.NodeHashRegex, result: "resolve-test1"},
+		{name: "metadata", node: &core.Node{Id: "pod", Metadata: metadata("resolve-test10")}, nodeHash: config.NodeHashMetadata, result: "resolve-test10"}, //nolint:lll
+		{name: "metadata empty", node: &core.Node{Id: "pod"}, nodeHash: config.NodeHashMetadata, result: "pod"},
+	}
+
+	for _, test := range tests {
+		if result := controlplane.ResolveConfigIDWith(test.node, test.nodeHash, regex, "config"); result != test.result {
+			t.Fatalf("%s: got %s, want %s", test.name, result, test.result)
+		}
+	}
+}
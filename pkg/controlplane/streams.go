@@ -0,0 +1,423 @@
+/*
+Copyright paskal.maksim@gmail.com
+Licensed under the Apache License, Version 2.0 (the "License")
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package controlplane
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"sort"
+	"sync"
+	"time"
+
+	core "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
+	"github.com/maksim-paskal/envoy-control-plane/pkg/metrics"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/peer"
+)
+
+type connectedStream struct {
+	node     *core.Node
+	peer     string
+	clientCN string
+	typeURLs map[string]bool
+	// ACK/NACK states by type url
+	acks map[string]*AckState
+	// time of stream open and last request
+	connected   time.Time
+	lastRequest time.Time
+}
+
+type connectedStreams struct {
+	mutex   sync.RWMutex
+	streams map[int64]*connectedStream
+	delta   bool
+}
+
+// sotw and delta servers have own stream ids.
+var (
+	streams = &connectedStreams{
+		streams: make(map[int64]*connectedStream),
+	}
+	deltaStreams = &connectedStreams{
+		streams: make(map[int64]*connectedStream),
+		delta:   true,
+	}
+)
+
+// connected envoy stream.
+type StreamInfo struct {
+	StreamID     int64
+	Delta        bool
+	NodeID       string
+	NodeHash     string
+	Cluster      string
+	Region       string
+	Zone         string
+	SubZone      string
+	BuildVersion string
+	UserAgent    string
+	// remote address and client certificate common name from grpc peer
+	Peer            string
+	ClientCN        string
+	TypeURLs        []string
+	ConnectedTime   time.Time
+	LastRequestTime time.Time
+}
+
+func (s *connectedStreams) open(ctx context.Context, streamID int64) {
+	stream := &connectedStream{
+		typeURLs:  make(map[string]bool),
+		acks:      make(map[string]*AckState),
+		connected: time.Now(),
+	}
+
+	if p, ok := peer.FromContext(ctx); ok {
+		if p.Addr != nil {
+			stream.peer = p.Addr.String()
+		}
+
+		if tlsInfo, ok := p.AuthInfo.(credentials.TLSInfo); ok && len(tlsInfo.State.PeerCertificates) > 0 {
+			stream.clientCN = tlsInfo.State.PeerCertificates[0].Subject.CommonName
+		}
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.streams[streamID] = stream
+}
+
+// node can be sent only in first request of stream.
+func (s *connectedStreams) request(streamID int64, node *core.Node, typeURL string) {
+	s.mutex.Lock()
+
+	isNewNode := false
+	isNewType := false
+
+	stream, ok := s.streams[streamID]
+	if ok {
+		stream.lastRequest = time.Now()
+
+		if stream.node == nil && len(node.GetId()) > 0 {
+			stream.node = node
+			isNewNode = true
+		}
+
+		if len(typeURL) > 0 && !stream.typeURLs[typeURL] {
+			stream.typeURLs[typeURL] = true
+			isNewType = true
+		}
+	}
+
+	streamNode := stream.getNode()
+
+	s.mutex.Unlock()
+
+	if streamNode != nil && (isNewNode || isNewType) {
+		updateStreamMetrics(streamNode, []string{typeURL})
+	}
+
+	// per node config must be rendered before watch is created
+	if isNewNode && isPerNode(ResolveConfigID(node)) && OnNewNode != nil {
+		OnNewNode(node)
+	}
+}
+
+func (s *connectedStreams) close(streamID int64) {
+	s.mutex.Lock()
+
+	stream, ok := s.streams[streamID]
+
+	delete(s.streams, streamID)
+
+	s.mutex.Unlock()
+
+	if !ok || stream.node == nil {
+		return
+	}
+
+	typeURLs := make([]string, 0, len(stream.typeURLs))
+
+	for typeURL := range stream.typeURLs {
+		typeURLs = append(typeURLs, typeURL)
+	}
+
+	updateStreamMetrics(stream.node, typeURLs)
+
+	deleteStreamAcks(stream.node.GetId(), stream)
+
+	if !isPerNode(ResolveConfigID(stream.node)) {
+		return
+	}
+
+	nodeHash := GetNodeHash(stream.node)
+
+	if !hasNodeHash(nodeHash) && OnNodeDisconnected != nil {
+		OnNodeDisconnected(stream.node)
+	}
+}
+
+// node of stream, nil if stream has no requests.
+func (s *connectedStreams) getNode(streamID int64) *core.Node {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	return s.streams[streamID].getNode()
+}
+
+func (stream *connectedStream) getNode() *core.Node {
+	if stream == nil {
+		return nil
+	}
+
+	return stream.node
+}
+
+func (s *connectedStreams) addStreamInfos(result []*StreamInfo) []*StreamInfo {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	for streamID, stream := range s.streams {
+		info := &StreamInfo{
+			StreamID:        streamID,
+			Delta:           s.delta,
+			Peer:            stream.peer,
+			ClientCN:        stream.clientCN,
+			TypeURLs:        make([]string, 0, len(stream.typeURLs)),
+			ConnectedTime:   stream.connected,
+			LastRequestTime: stream.lastRequest,
+		}
+
+		if node := stream.node; node != nil {
+			info.NodeID = node.GetId()
+			info.NodeHash = GetNodeHash(node)
+			info.Cluster = node.GetCluster()
+			info.Region = node.GetLocality().GetRegion()
+			info.Zone = node.GetLocality().GetZone()
+			info.SubZone = node.GetLocality().GetSubZone()
+			info.BuildVersion = getBuildVersion(node)
+			info.UserAgent = node.GetUserAgentName()
+		}
+
+		for typeURL := range stream.typeURLs {
+			info.TypeURLs = append(info.TypeURLs, typeURL)
+		}
+
+		sort.Strings(info.TypeURLs)
+
+		result = append(result, info)
+	}
+
+	return result
+}
+
+// all connected streams sorted by node id.
+func GetStreams() []*StreamInfo {
+	result := make([]*StreamInfo, 0)
+
+	result = streams.addStreamInfos(result)
+	result = deltaStreams.addStreamInfos(result)
+
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].NodeID != result[j].NodeID {
+			return result[i].NodeID < result[j].NodeID
+		}
+
+		if result[i].Delta != result[j].Delta {
+			return !result[i].Delta
+		}
+
+		return result[i].StreamID < result[j].StreamID
+	})
+
+	return result
+}
+
+func getBuildVersion(node *core.Node) string {
+	version := node.GetUserAgentBuildVersion().GetVersion()
+	if version == nil {
+		return ""
+	}
+
+	return fmt.Sprintf("%d.%d.%d", version.GetMajorNumber(), version.GetMinorNumber(), version.GetPatch())
+}
+
+// labels of connected streams metric.
+func getStreamLabels(node *core.Node) []string {
+	return []string{
+		node.GetId(),
+		node.GetCluster(),
+		node.GetLocality().GetZone(),
+		getBuildVersion(node),
+	}
+}
+
+// streams with same metric labels as node, subscriptions are counted for all streams of node id.
+func (s *connectedStreams) countStreams(node *core.Node, typeCounts map[string]int) int {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	labels := getStreamLabels(node)
+	count := 0
+
+	for _, stream := range s.streams {
+		if stream.node.GetId() != node.GetId() {
+			continue
+		}
+
+		for typeURL := range stream.typeURLs {
+			typeCounts[typeURL]++
+		}
+
+		if slices.Equal(getStreamLabels(stream.node), labels) {
+			count++
+		}
+	}
+
+	return count
+}
+
+// set number of streams of node and subscriptions of resource types, zero values are deleted.
+func updateStreamMetrics(node *core.Node, typeURLs []string) {
+	typeCounts := make(map[string]int)
+
+	count := streams.countStreams(node, typeCounts) + deltaStreams.countStreams(node, typeCounts)
+
+	labels := getStreamLabels(node)
+
+	if count > 0 {
+		metrics.XdsConnectedStreams.WithLabelValues(labels...).Set(float64(count))
+	} else {
+		metrics.XdsConnectedStreams.DeleteLabelValues(labels...)
+	}
+
+	for _, typeURL := range typeURLs {
+		if len(typeURL) == 0 {
+			continue
+		}
+
+		if typeCount := typeCounts[typeURL]; typeCount > 0 {
+			metrics.XdsSubscribedStreams.WithLabelValues(node.GetId(), getMetricsType(typeURL)).Set(float64(typeCount))
+		} else {
+			metrics.XdsSubscribedStreams.DeleteLabelValues(node.GetId(), getMetricsType(typeURL))
+		}
+	}
+}
+
+func (s *connectedStreams) hasNodeHash(nodeHash string) bool {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	for _, stream := range s.streams {
+		if stream.node != nil && GetNodeHash(stream.node) == nodeHash {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (s *connectedStreams) addPeers(nodeID string, peers map[string]bool) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	for _, stream := range s.streams {
+		if stream.node != nil && ResolveConfigID(stream.node) == nodeID {
+			peers[stream.peer] = true
+		}
+	}
+}
+
+func (s *connectedStreams) addNodes(nodeID string, nodes map[string]*core.Node) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	for _, stream := range s.streams {
+		if stream.node != nil && ResolveConfigID(stream.node) == nodeID {
+			nodes[GetNodeHash(stream.node)] = stream.node
+		}
+	}
+}
+
+// return connected envoys with node id, one node for every node hash.
+func GetNodes(nodeID string) []*core.Node {
+	nodes := make(map[string]*core.Node)
+
+	streams.addNodes(nodeID, nodes)
+	deltaStreams.addNodes(nodeID, nodes)
+
+	result := make([]*core.Node, 0, len(nodes))
+
+	for _, node := range nodes {
+		result = append(result, node)
+	}
+
+	return result
+}
+
+func (s *connectedStreams) addStaleNodeKeys(configID string, keys map[string]bool) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	for _, stream := range s.streams {
+		if stream.node == nil || stream.node.GetId() == configID || ResolveConfigID(stream.node) != configID {
+			continue
+		}
+
+		keys[stream.node.GetId()] = true
+	}
+}
+
+// envoys that connected before config was loaded are waiting for snapshot with node id,
+// return node ids with watches that now resolves to config id.
+func GetStaleNodeKeys(configID string) []string {
+	keys := make(map[string]bool)
+
+	streams.addStaleNodeKeys(configID, keys)
+	deltaStreams.addStaleNodeKeys(configID, keys)
+
+	result := make([]string, 0, len(keys))
+
+	for key := range keys {
+		statusInfo := SnapshotCache.GetStatusInfo(key)
+		if statusInfo == nil {
+			continue
+		}
+
+		if statusInfo.GetNumWatches() > 0 || statusInfo.GetNumDeltaWatches() > 0 {
+			result = append(result, key)
+		}
+	}
+
+	return result
+}
+
+// return true if any stream uses snapshot with node hash.
+func IsNodeHashConnected(nodeHash string) bool {
+	return hasNodeHash(nodeHash)
+}
+
+func hasNodeHash(nodeHash string) bool {
+	return streams.hasNodeHash(nodeHash) || deltaStreams.hasNodeHash(nodeHash)
+}
+
+// return number of envoys connected with node id, one envoy uses one connection for all streams.
+func GetConnectedNodes(nodeID string) int {
+	peers := make(map[string]bool)
+
+	streams.addPeers(nodeID, peers)
+	deltaStreams.addPeers(nodeID, peers)
+
+	return len(peers)
+}
@@ -0,0 +1,66 @@
+/*
+Copyright paskal.maksim@gmail.com
+Licensed under the Apache License, Version 2.0 (the "License")
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package controlplane_test
+
+import (
+	"context"
+	"testing"
+
+	core "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
+	discovery "github.com/envoyproxy/go-control-plane/envoy/service/discovery/v3"
+	resource "github.com/envoyproxy/go-control-plane/pkg/resource/v3"
+	"github.com/maksim-paskal/envoy-control-plane/pkg/controlplane"
+	"github.com/maksim-paskal/envoy-control-plane/pkg/metrics"
+)
+
+func TestConnectedStreamsMetrics(t *testing.T) {
+	t.Parallel()
+
+	const (
+		nodeID  = "streams-test-id"
+		streamA = int64(3001)
+		streamB = int64(3002)
+	)
+
+	ctx := context.Background()
+	cb := &controlplane.Callbacks{}
+
+	nodes := map[int64]*core.Node{
+		streamA: {Id: nodeID, Cluster: "test", Locality: &core.Locality{Zone: "zone-a"}},
+		streamB: {Id: nodeID, Cluster: "test", Locality: &core.Locality{Zone: "zone-b"}},
+	}
+
+	for _, streamID := range []int64{streamA, streamB} {
+		if err := cb.OnStreamOpen(ctx, streamID, resource.AnyType); err != nil {
+			t.Fatal(err)
+		}
+
+		req := &discovery.DiscoveryRequest{Node: nodes[streamID], TypeUrl: resource.ClusterType}
+
+		if err := cb.OnStreamRequest(streamID, req); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	cb.OnStreamClosed(streamA, nodes[streamA])
+
+	if metrics.XdsConnectedStreams.DeleteLabelValues(nodeID, "test", "zone-a", "") {
+		t.Fatal("metric of closed stream must be deleted")
+	}
+
+	if !metrics.XdsConnectedStreams.DeleteLabelValues(nodeID, "test", "zone-b", "") {
+		t.Fatal("metric of connected stream must exist")
+	}
+
+	cb.OnStreamClosed(streamB, nodes[streamB])
+}
@@ -0,0 +1,476 @@
+/*
+Copyright paskal.maksim@gmail.com
+Licensed under the Apache License, Version 2.0 (the "License")
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package lint
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	cluster "github.com/envoyproxy/go-control-plane/envoy/config/cluster/v3"
+	endpoint "github.com/envoyproxy/go-control-plane/envoy/config/endpoint/v3"
+	listener "github.com/envoyproxy/go-control-plane/envoy/config/listener/v3"
+	route "github.com/envoyproxy/go-control-plane/envoy/config/route/v3"
+	tls "github.com/envoyproxy/go-control-plane/envoy/extensions/transport_sockets/tls/v3"
+	"github.com/maksim-paskal/envoy-control-plane/pkg/config"
+	"github.com/maksim-paskal/utils-go"
+	"github.com/pkg/errors"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoregistry"
+	"gopkg.in/yaml.v3"
+)
+
+const defaultNamespace = "default"
+
+var yamlErrorLine = regexp.MustCompile(`line (\d+)`)
+
+// lint problem, line is line in file or 0 if unknown.
+type Issue struct {
+	File    string
+	Line    int
+	Source  string
+	Message string
+}
+
+func (i Issue) String() string {
+	return fmt.Sprintf("%s:%d: [%s] %s", i.File, i.Line, i.Source, i.Message)
+}
+
+// node config from file, ConfigMap key or whole file with config.
+type configSource struct {
+	file      string
+	namespace string
+	name      string
+	key       string
+	text      string
+	// line in file before first line of config
+	lineOffset int
+}
+
+func (s *configSource) String() string {
+	if len(s.name) == 0 {
+		return s.key
+	}
+
+	return s.namespace + "/" + s.name + "/" + s.key
+}
+
+type Linter struct {
+	// template data for config rendering
+	data *config.TemplateData
+	// clusters from envoy bootstrap config
+	staticClusters []string
+	sources        []*configSource
+	issues         []Issue
+}
+
+func New(data *config.TemplateData) *Linter {
+	return &Linter{
+		data: data,
+	}
+}
+
+// clusters that defined in envoy bootstrap config.
+func (l *Linter) AddStaticClusters(names ...string) {
+	l.staticClusters = append(l.staticClusters, names...)
+}
+
+// load ConfigMap manifests or config file, fragments are registered for all configs.
+func (l *Linter) AddFile(file string) error {
+	content, err := os.ReadFile(file)
+	if err != nil {
+		return errors.Wrap(err, "error reading file")
+	}
+
+	configMaps, isManifest, err := parseConfigMaps(file, content)
+	if err != nil {
+		return err
+	}
+
+	// file that is not kubernetes manifest is one node config
+	if !isManifest {
+		l.sources = append(l.sources, &configSource{
+			file: file,
+			key:  strings.TrimSuffix(filepath.Base(file), filepath.Ext(file)),
+			text: string(content),
+		})
+
+		return nil
+	}
+
+	for _, cm := range configMaps {
+		if cm.Metadata.Annotations[config.AnnotationFragments] == "true" {
+			config.SetFragments(cm.Metadata.Namespace, cm.Metadata.Name, cm.data())
+
+			continue
+		}
+
+		l.sources = append(l.sources, cm.sources(file)...)
+	}
+
+	return nil
+}
+
+// lint all loaded configs, returns all found issues.
+func (l *Linter) Lint() []Issue {
+	l.issues = make([]Issue, 0)
+
+	for _, source := range l.sources {
+		l.lintSource(source)
+	}
+
+	sort.SliceStable(l.issues, func(i, j int) bool {
+		if l.issues[i].File != l.issues[j].File {
+			return l.issues[i].File < l.issues[j].File
+		}
+
+		return l.issues[i].Line < l.issues[j].Line
+	})
+
+	return l.issues
+}
+
+func (l *Linter) addIssue(source *configSource, line int, format string, args ...interface{}) {
+	if line > 0 {
+		line += source.lineOffset
+	}
+
+	l.issues = append(l.issues, Issue{
+		File:    source.file,
+		Line:    line,
+		Source:  source.String(),
+		Message: fmt.Sprintf(format, args...),
+	})
+}
+
+// line of yaml error in rendered config.
+func getErrorLine(err error) int {
+	if match := yamlErrorLine.FindStringSubmatch(err.Error()); len(match) > 1 {
+		if line, err := strconv.Atoi(match[1]); err == nil {
+			return line
+		}
+	}
+
+	return 1
+}
+
+func (l *Linter) lintSource(source *configSource) {
+	rendered, err := config.RenderConfigMapYaml(source.namespace, source.key, source.text, l.data)
+	if err != nil {
+		l.addIssue(source, 1, "%s", err.Error())
+
+		return
+	}
+
+	lines, err := getResourceLines(rendered)
+	if err != nil {
+		l.addIssue(source, getErrorLine(err), "%s", err.Error())
+
+		return
+	}
+
+	configType, err := config.ParseConfigMapYaml(source.namespace, source.key, source.text, l.data)
+	if err != nil {
+		l.addIssue(source, getErrorLine(err), "%s", err.Error())
+
+		return
+	}
+
+	parsed := newParsedConfig(l.staticClusters)
+
+	for _, section := range sections {
+		for index, item := range section.items(configType) {
+			path := getResourcePath(section.name, index, item, section.key)
+			line := lines.get(section.name, getItemKey(item, section.key))
+
+			if types := getUnregisteredTypes(item); len(types) > 0 {
+				for _, typeURL := range types {
+					l.addIssue(source, line, "%s: unregistered typed_config type %s", path, typeURL)
+				}
+
+				continue
+			}
+
+			message, err := section.toMessage(item)
+			if err != nil {
+				l.addIssue(source, line, "%s: %s", path, err.Error())
+
+				continue
+			}
+
+			if v, ok := message.(interface{ Validate() error }); ok {
+				if err := v.Validate(); err != nil {
+					l.addIssue(source, line, "%s: %s", path, err.Error())
+
+					continue
+				}
+			}
+
+			parsed.add(section.name, line, path, message)
+		}
+	}
+
+	for _, kubernetes := range configType.Kubernetes {
+		if !parsed.edsClusters[kubernetes.ClusterName] {
+			line := lines.get("kubernetes", kubernetes.ClusterName)
+
+			l.addIssue(source, line, "kubernetes cluster_name %s has no EDS cluster", kubernetes.ClusterName)
+		}
+	}
+
+	for _, reference := range parsed.checkReferences() {
+		l.addIssue(source, reference.line, "%s: %s", reference.path, reference.message)
+	}
+}
+
+type section struct {
+	name       string
+	key        string
+	items      func(*config.ConfigType) []interface{}
+	newMessage func() proto.Message
+}
+
+func (s *section) toMessage(item interface{}) (proto.Message, error) {
+	itemJSON, err := utils.GetJSONfromYAML(item)
+	if err != nil {
+		return nil, errors.Wrap(err, "utils.GetJSONfromYAML")
+	}
+
+	message := s.newMessage()
+
+	if err := protojson.Unmarshal(itemJSON, message); err != nil {
+		return nil, errors.Wrap(err, "protojson.Unmarshal")
+	}
+
+	return message, nil
+}
+
+var sections = []*section{
+	{
+		name:       "clusters",
+		key:        "name",
+		items:      func(c *config.ConfigType) []interface{} { return c.Clusters },
+		newMessage: func() proto.Message { return &cluster.Cluster{} },
+	},
+	{
+		name:       "routes",
+		key:        "name",
+		items:      func(c *config.ConfigType) []interface{} { return c.Routes },
+		newMessage: func() proto.Message { return &route.RouteConfiguration{} },
+	},
+	{
+		name:       "listeners",
+		key:        "name",
+		items:      func(c *config.ConfigType) []interface{} { return c.Listeners },
+		newMessage: func() proto.Message { return &listener.Listener{} },
+	},
+	{
+		name:       "secrets",
+		key:        "name",
+		items:      func(c *config.ConfigType) []interface{} { return c.Secrets },
+		newMessage: func() proto.Message { return &tls.Secret{} },
+	},
+	{
+		name:       "endpoints",
+		key:        "cluster_name",
+		items:      func(c *config.ConfigType) []interface{} { return c.Endpoints },
+		newMessage: func() proto.Message { return &endpoint.ClusterLoadAssignment{} },
+	},
+}
+
+// path of resource for messages, like clusters[1](name=local_service).
+func getResourcePath(section string, index int, item interface{}, key string) string {
+	path := fmt.Sprintf("%s[%d]", section, index)
+
+	if name := getItemKey(item, key); len(name) > 0 {
+		path += fmt.Sprintf("(%s=%s)", key, name)
+	}
+
+	return path
+}
+
+func getItemKey(item interface{}, key string) string {
+	if m, ok := item.(map[string]interface{}); ok {
+		if value, ok := m[key].(string); ok {
+			return value
+		}
+	}
+
+	return ""
+}
+
+// all @type values that are not registered in protobuf registry.
+func getUnregisteredTypes(item interface{}) []string {
+	result := make([]string, 0)
+
+	switch value := item.(type) {
+	case map[string]interface{}:
+		if typeURL, ok := value["@type"].(string); ok {
+			if _, err := protoregistry.GlobalTypes.FindMessageByURL(typeURL); err != nil {
+				result = append(result, typeURL)
+			}
+		}
+
+		for _, v := range value {
+			result = append(result, getUnregisteredTypes(v)...)
+		}
+	case []interface{}:
+		for _, v := range value {
+			result = append(result, getUnregisteredTypes(v)...)
+		}
+	}
+
+	return result
+}
+
+// lines of resources in rendered config by section and resource name.
+type resourceLines map[string]map[string]int
+
+func (r resourceLines) get(section, name string) int {
+	if line, ok := r[section][name]; ok {
+		return line
+	}
+
+	// resource from fragment or without name
+	return r[section][""]
+}
+
+func getResourceLines(rendered []byte) (resourceLines, error) {
+	result := make(resourceLines)
+
+	var document yaml.Node
+
+	if err := yaml.Unmarshal(rendered, &document); err != nil {
+		return nil, errors.Wrap(err, "yaml.Unmarshal")
+	}
+
+	if len(document.Content) == 0 || document.Content[0].Kind != yaml.MappingNode {
+		return result, nil
+	}
+
+	root := document.Content[0]
+
+	for i := 0; i+1 < len(root.Content); i += 2 {
+		sectionName := root.Content[i].Value
+		sectionNode := root.Content[i+1]
+
+		result[sectionName] = map[string]int{"": root.Content[i].Line}
+
+		if sectionNode.Kind != yaml.SequenceNode {
+			continue
+		}
+
+		for _, item := range sectionNode.Content {
+			for j := 0; j+1 < len(item.Content); j += 2 {
+				if key := item.Content[j].Value; key == "name" || key == "cluster_name" {
+					result[sectionName][item.Content[j+1].Value] = item.Line
+				}
+			}
+		}
+	}
+
+	return result, nil
+}
+
+type configMap struct {
+	Metadata struct {
+		Name        string            `yaml:"name"`
+		Namespace   string            `yaml:"namespace"`
+		Annotations map[string]string `yaml:"annotations"`
+	} `yaml:"metadata"`
+	Kind string    `yaml:"kind"`
+	Data yaml.Node `yaml:"data"`
+}
+
+func (cm *configMap) data() map[string]string {
+	result := make(map[string]string)
+
+	for i := 0; i+1 < len(cm.Data.Content); i += 2 {
+		result[cm.Data.Content[i].Value] = cm.Data.Content[i+1].Value
+	}
+
+	return result
+}
+
+func (cm *configMap) sources(file string) []*configSource {
+	result := make([]*configSource, 0)
+
+	for i := 0; i+1 < len(cm.Data.Content); i += 2 {
+		value := cm.Data.Content[i+1]
+
+		// block scalar starts on next line
+		lineOffset := value.Line - 1
+		if value.Style == yaml.LiteralStyle || value.Style == yaml.FoldedStyle {
+			lineOffset = value.Line
+		}
+
+		result = append(result, &configSource{
+			file:       file,
+			namespace:  cm.Metadata.Namespace,
+			name:       cm.Metadata.Name,
+			key:        cm.Data.Content[i].Value,
+			text:       value.Value,
+			lineOffset: lineOffset,
+		})
+	}
+
+	return result
+}
+
+// all ConfigMaps in yaml documents, returns false if file is not kubernetes manifest.
+func parseConfigMaps(file string, content []byte) ([]*configMap, bool, error) {
+	result := make([]*configMap, 0)
+	isManifest := false
+
+	decoder := yaml.NewDecoder(bytes.NewReader(content))
+
+	for {
+		cm := &configMap{}
+
+		if err := decoder.Decode(cm); err != nil {
+			if errors.Is(err, io.EOF) {
+				break
+			}
+
+			// templated config is not valid yaml
+			if !isManifest {
+				return nil, false, nil
+			}
+
+			return nil, true, errors.Wrapf(err, "error parsing %s", file)
+		}
+
+		if len(cm.Kind) > 0 {
+			isManifest = true
+		}
+
+		if cm.Kind != "ConfigMap" {
+			continue
+		}
+
+		if len(cm.Metadata.Namespace) == 0 {
+			cm.Metadata.Namespace = defaultNamespace
+		}
+
+		result = append(result, cm)
+	}
+
+	return result, isManifest, nil
+}
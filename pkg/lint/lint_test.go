@@ -0,0 +1,64 @@
+/*
+Copyright paskal.maksim@gmail.com
+Licensed under the Apache License, Version 2.0 (the "License")
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package lint_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/maksim-paskal/envoy-control-plane/pkg/config"
+	_ "github.com/maksim-paskal/envoy-control-plane/pkg/extensions"
+	"github.com/maksim-paskal/envoy-control-plane/pkg/lint"
+)
+
+func TestLint(t *testing.T) {
+	t.Parallel()
+
+	linter := lint.New(config.NewTemplateData(nil))
+
+	if err := linter.AddFile("testdata/configmap.yaml"); err != nil {
+		t.Fatal(err)
+	}
+
+	issues := linter.Lint()
+
+	// all issues must be reported with line in file
+	want := map[string]int{
+		"kubernetes cluster_name service-b has no EDS cluster":          12,
+		"rds route_config_name unknown_route has no RouteConfiguration": 22,
+		"unregistered typed_config type type.googleapis.com/unknown":    41,
+		"cluster service-c is not defined":                              52,
+	}
+
+	if len(issues) != len(want) {
+		t.Fatalf("issues %v, want %d issues", issues, len(want))
+	}
+
+	for _, issue := range issues {
+		found := false
+
+		for message, line := range want {
+			if strings.Contains(issue.Message, message) {
+				found = true
+
+				if issue.Line != line {
+					t.Fatalf("%s line %d != %d", message, issue.Line, line)
+				}
+			}
+		}
+
+		if !found {
+			t.Fatalf("unknown issue %s", issue.String())
+		}
+	}
+}
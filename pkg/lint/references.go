@@ -0,0 +1,240 @@
+/*
+Copyright paskal.maksim@gmail.com
+Licensed under the Apache License, Version 2.0 (the "License")
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package lint
+
+import (
+	"fmt"
+
+	cluster "github.com/envoyproxy/go-control-plane/envoy/config/cluster/v3"
+	core "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
+	listener "github.com/envoyproxy/go-control-plane/envoy/config/listener/v3"
+	route "github.com/envoyproxy/go-control-plane/envoy/config/route/v3"
+	hcm "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/network/http_connection_manager/v3"
+	tcpproxy "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/network/tcp_proxy/v3"
+	tls "github.com/envoyproxy/go-control-plane/envoy/extensions/transport_sockets/tls/v3"
+	"github.com/envoyproxy/go-control-plane/pkg/wellknown"
+	"github.com/maksim-paskal/envoy-control-plane/pkg/config"
+	"google.golang.org/protobuf/proto"
+)
+
+// resource that uses names of other resources.
+type parsedResource struct {
+	line    int
+	path    string
+	message proto.Message
+}
+
+type referenceIssue struct {
+	line    int
+	path    string
+	message string
+}
+
+// parsed resources of config for cross-reference checks.
+type parsedConfig struct {
+	clusters    map[string]bool
+	edsClusters map[string]bool
+	routes      map[string]bool
+	secrets     map[string]bool
+	resources   []*parsedResource
+	issues      []referenceIssue
+}
+
+func newParsedConfig(staticClusters []string) *parsedConfig {
+	p := &parsedConfig{
+		clusters:    make(map[string]bool),
+		edsClusters: make(map[string]bool),
+		routes:      make(map[string]bool),
+		// secrets added by control plane
+		secrets: map[string]bool{
+			*config.Get().SSLName: true,
+			"validation":          true,
+		},
+	}
+
+	for _, name := range staticClusters {
+		p.clusters[name] = true
+		p.edsClusters[name] = true
+	}
+
+	return p
+}
+
+func (p *parsedConfig) add(section string, line int, path string, message proto.Message) {
+	switch resource := message.(type) {
+	case *cluster.Cluster:
+		p.clusters[resource.GetName()] = true
+
+		if resource.GetType() == cluster.Cluster_EDS {
+			p.edsClusters[resource.GetName()] = true
+		}
+	case *route.RouteConfiguration:
+		p.routes[resource.GetName()] = true
+	case *tls.Secret:
+		p.secrets[resource.GetName()] = true
+	}
+
+	if section == "clusters" || section == "routes" || section == "listeners" {
+		p.resources = append(p.resources, &parsedResource{
+			line:    line,
+			path:    path,
+			message: message,
+		})
+	}
+}
+
+// check that all used clusters, routes and secrets are defined.
+func (p *parsedConfig) checkReferences() []referenceIssue {
+	p.issues = make([]referenceIssue, 0)
+
+	for _, resource := range p.resources {
+		switch message := resource.message.(type) {
+		case *cluster.Cluster:
+			p.checkTransportSocket(resource, message.GetTransportSocket())
+		case *route.RouteConfiguration:
+			p.checkRouteConfiguration(resource, message)
+		case *listener.Listener:
+			for _, filterChain := range append(message.GetFilterChains(), message.GetDefaultFilterChain()) {
+				p.checkFilterChain(resource, filterChain)
+			}
+		}
+	}
+
+	return p.issues
+}
+
+func (p *parsedConfig) addIssue(resource *parsedResource, format string, args ...interface{}) {
+	p.issues = append(p.issues, referenceIssue{
+		line:    resource.line,
+		path:    resource.path,
+		message: fmt.Sprintf(format, args...),
+	})
+}
+
+func (p *parsedConfig) checkCluster(resource *parsedResource, name string) {
+	if len(name) > 0 && !p.clusters[name] {
+		p.addIssue(resource, "cluster %s is not defined", name)
+	}
+}
+
+func (p *parsedConfig) checkRouteConfiguration(resource *parsedResource, routeConfiguration *route.RouteConfiguration) {
+	for _, virtualHost := range routeConfiguration.GetVirtualHosts() {
+		for _, r := range virtualHost.GetRoutes() {
+			action := r.GetRoute()
+
+			p.checkCluster(resource, action.GetCluster())
+
+			for _, weightedCluster := range action.GetWeightedClusters().GetClusters() {
+				p.checkCluster(resource, weightedCluster.GetName())
+			}
+
+			for _, mirrorPolicy := range action.GetRequestMirrorPolicies() {
+				p.checkCluster(resource, mirrorPolicy.GetCluster())
+			}
+		}
+	}
+}
+
+func (p *parsedConfig) checkFilterChain(resource *parsedResource, filterChain *listener.FilterChain) {
+	if filterChain == nil {
+		return
+	}
+
+	p.checkTransportSocket(resource, filterChain.GetTransportSocket())
+
+	for _, filter := range filterChain.GetFilters() {
+		switch filter.GetName() {
+		case wellknown.HTTPConnectionManager:
+			m := hcm.HttpConnectionManager{}
+
+			if err := filter.GetTypedConfig().UnmarshalTo(&m); err != nil {
+				p.addIssue(resource, "error unmarshal to HttpConnectionManager: %s", err.Error())
+
+				continue
+			}
+
+			if rds := m.GetRds(); rds != nil && !p.routes[rds.GetRouteConfigName()] {
+				p.addIssue(resource, "rds route_config_name %s has no RouteConfiguration", rds.GetRouteConfigName())
+			}
+
+			p.checkRouteConfiguration(resource, m.GetRouteConfig())
+		case wellknown.TCPProxy:
+			m := tcpproxy.TcpProxy{}
+
+			if err := filter.GetTypedConfig().UnmarshalTo(&m); err != nil {
+				p.addIssue(resource, "error unmarshal to TcpProxy: %s", err.Error())
+
+				continue
+			}
+
+			p.checkCluster(resource, m.GetCluster())
+
+			for _, weightedCluster := range m.GetWeightedClusters().GetClusters() {
+				p.checkCluster(resource, weightedCluster.GetName())
+			}
+		}
+	}
+}
+
+func (p *parsedConfig) checkTransportSocket(resource *parsedResource, transportSocket *core.TransportSocket) {
+	if transportSocket.GetName() != wellknown.TransportSocketTLS || transportSocket.GetTypedConfig() == nil {
+		return
+	}
+
+	var commonTLSContext *tls.CommonTlsContext
+
+	downstream := tls.DownstreamTlsContext{}
+	upstream := tls.UpstreamTlsContext{}
+
+	switch {
+	case transportSocket.GetTypedConfig().MessageIs(&downstream):
+		if err := transportSocket.GetTypedConfig().UnmarshalTo(&downstream); err != nil {
+			p.addIssue(resource, "error unmarshal to DownstreamTlsContext: %s", err.Error())
+
+			return
+		}
+
+		commonTLSContext = downstream.GetCommonTlsContext()
+	case transportSocket.GetTypedConfig().MessageIs(&upstream):
+		if err := transportSocket.GetTypedConfig().UnmarshalTo(&upstream); err != nil {
+			p.addIssue(resource, "error unmarshal to UpstreamTlsContext: %s", err.Error())
+
+			return
+		}
+
+		commonTLSContext = upstream.GetCommonTlsContext()
+	default:
+		return
+	}
+
+	sdsConfigs := append([]*tls.SdsSecretConfig{}, commonTLSContext.GetTlsCertificateSdsSecretConfigs()...)
+	sdsConfigs = append(sdsConfigs,
+		commonTLSContext.GetValidationContextSdsSecretConfig(),
+		commonTLSContext.GetCombinedValidationContext().GetValidationContextSdsSecretConfig(),
+	)
+
+	for _, sdsConfig := range sdsConfigs {
+		if sdsConfig == nil || !isControlPlaneSds(sdsConfig.GetSdsConfig()) {
+			continue
+		}
+
+		if !p.secrets[sdsConfig.GetName()] {
+			p.addIssue(resource, "SDS secret %s is not defined", sdsConfig.GetName())
+		}
+	}
+}
+
+// secrets without sds_config or with ads are loaded from control plane.
+func isControlPlaneSds(sdsConfig *core.ConfigSource) bool {
+	return sdsConfig == nil || sdsConfig.GetAds() != nil
+}
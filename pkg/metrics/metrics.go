@@ -28,6 +28,16 @@ var (
 		Name:      "leader_election_is_master",
 		Help:      "0 if not master, 1 if master",
 	})
+	LeaderElectionTransitions = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "leader_election_transitions_total",
+		Help:      "The total number of leader election transitions",
+	}, []string{"transition"})
+	LeaderElectionLastTransition = promauto.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "leader_election_last_transition_timestamp_seconds",
+		Help:      "Unix time of last leader election transition",
+	})
 	GrpcOnStreamOpen = promauto.NewCounter(prometheus.CounterOpts{
 		Namespace: namespace,
 		Name:      "on_stream_open_total",
@@ -119,6 +129,91 @@ var (
 		Help:      "The total number of Push",
 	})
 
+	ConfigmapsstoreRejected = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "configmapsstore_rejected_total",
+		Help:      "The total number of rejected config updates",
+	}, []string{"node"})
+
+	ConfigmapsstoreLastRejected = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "configmapsstore_last_rejected",
+		Help:      "1 if last config update of node was rejected, 0 if applied",
+	}, []string{"node"})
+
+	ConfigmapsstoreRollback = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "configmapsstore_rollback_total",
+		Help:      "The total number of rollbacks to last acknowledged config",
+	}, []string{"node"})
+
+	XdsNack = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "xds_nack_total",
+		Help:      "The total number of config responses rejected by envoy",
+	}, []string{"node", "type"})
+
+	XdsLastNack = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "xds_last_nack",
+		Help:      "1 if last config response was rejected by envoy, 0 if accepted",
+	}, []string{"node", "type"})
+
+	AccessLogEntries = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "accesslog_entries_total",
+		Help:      "The total number of access log entries streamed by envoy",
+	}, []string{"type"})
+
+	AccessLogDropped = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "accesslog_dropped_total",
+		Help:      "The total number of access log entries dropped when buffer is full",
+	})
+
+	AccessLogSinkErrors = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "accesslog_sink_errors_total",
+		Help:      "The total number of failed writes to access log sink",
+	}, []string{"sink"})
+
+	EnvoyRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "envoy_requests_total",
+		Help:      "The total number of HTTP requests from envoy access logs",
+	}, []string{"node", "log_name", "upstream_cluster", "route_name", "code_class"})
+
+	EnvoyRequestDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "envoy_request_duration_seconds",
+		Help:      "The duration in seconds of HTTP requests from envoy access logs",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"node", "log_name", "upstream_cluster", "route_name"})
+
+	XdsConnectedStreams = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "xds_connected_streams",
+		Help:      "The number of open xDS streams of envoy",
+	}, []string{"node", "cluster", "zone", "build_version"})
+
+	XdsSubscribedStreams = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "xds_subscribed_streams",
+		Help:      "The number of open xDS streams of envoy that requested resource type",
+	}, []string{"node", "type"})
+
+	XdsSentVersion = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "xds_sent_version_info",
+		Help:      "Last version sent to envoy, value is always 1",
+	}, []string{"node", "type", "version"})
+
+	XdsAckedVersion = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "xds_acked_version_info",
+		Help:      "Last version accepted by envoy, value is always 1",
+	}, []string{"node", "type", "version"})
+
 	EndpointstoreAddFunc = promauto.NewCounter(prometheus.CounterOpts{
 		Namespace: namespace,
 		Name:      "endpointstore_add_total",
@@ -32,6 +32,8 @@ var (
 func TestMetricsInc(t *testing.T) {
 	t.Parallel()
 
+	metrics.LeaderElectionTransitions.WithLabelValues("started_leading").Inc()
+	metrics.LeaderElectionLastTransition.SetToCurrentTime()
 	metrics.GrpcOnStreamOpen.Inc()
 	metrics.GrpcOnStreamClosed.Inc()
 	metrics.GrpcOnStreamRequest.Inc()
@@ -45,6 +47,10 @@ func TestMetricsInc(t *testing.T) {
 	metrics.GrpcOnDeltaStreamClosed.Inc()
 	metrics.KubernetesAPIRequest.WithLabelValues("200").Inc()
 	metrics.KubernetesAPIRequestDuration.Observe(1)
+	metrics.ConfigmapsstoreRejected.WithLabelValues("test").Inc()
+	metrics.ConfigmapsstoreLastRejected.WithLabelValues("test").Set(1)
+	metrics.EnvoyRequests.WithLabelValues("test", "test", "test", "test", "2xx").Inc()
+	metrics.EnvoyRequestDuration.WithLabelValues("test", "test", "test", "test").Observe(1)
 }
 
 func TestMetricsHandler(t *testing.T) {
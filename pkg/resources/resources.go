@@ -14,6 +14,7 @@ package resources
 
 import (
 	"encoding/json"
+	"fmt"
 
 	cluster "github.com/envoyproxy/go-control-plane/envoy/config/cluster/v3"
 	endpoint "github.com/envoyproxy/go-control-plane/envoy/config/endpoint/v3"
@@ -23,12 +24,29 @@ import (
 	"github.com/envoyproxy/go-control-plane/pkg/cache/types"
 	"github.com/maksim-paskal/utils-go"
 	"github.com/pkg/errors"
-	log "github.com/sirupsen/logrus"
 	"google.golang.org/protobuf/encoding/protojson"
 )
 
 var errUnknownClass = errors.New("unknown class")
 
+// index and name of resource for error messages, like [1](name=local_service).
+func getResourcePath(index int, yamlObj interface{}) string {
+	path := fmt.Sprintf("[%d]", index)
+
+	obj, ok := yamlObj.(map[string]interface{})
+	if !ok {
+		return path
+	}
+
+	for _, key := range []string{"name", "cluster_name"} {
+		if name, ok := obj[key]; ok {
+			return fmt.Sprintf("%s(%s=%v)", path, key, name)
+		}
+	}
+
+	return path
+}
+
 func YamlToResources(yamlObj []interface{}, outType interface{}) ([]types.Resource, error) {
 	if len(yamlObj) == 0 {
 		return nil, nil
@@ -62,9 +80,7 @@ func YamlToResources(yamlObj []interface{}, outType interface{}) ([]types.Resour
 
 			err = protojson.Unmarshal(resourcesJSON, &resource)
 			if err != nil {
-				log.WithError(err).Errorf("json=%s", string(resourcesJSON))
-
-				return nil, errors.Wrap(err, "cluster.Cluster")
+				return nil, errors.Wrapf(err, "cluster.Cluster%s", getResourcePath(k, v))
 			}
 
 			results[k] = &resource
@@ -74,9 +90,7 @@ func YamlToResources(yamlObj []interface{}, outType interface{}) ([]types.Resour
 
 			err = protojson.Unmarshal(resourcesJSON, &resource)
 			if err != nil {
-				log.WithError(err).Errorf("json=\n%s", string(resourcesJSON))
-
-				return nil, errors.Wrap(err, "route.RouteConfiguration")
+				return nil, errors.Wrapf(err, "route.RouteConfiguration%s", getResourcePath(k, v))
 			}
 
 			results[k] = &resource
@@ -85,9 +99,7 @@ func YamlToResources(yamlObj []interface{}, outType interface{}) ([]types.Resour
 
 			err = protojson.Unmarshal(resourcesJSON, &resource)
 			if err != nil {
-				log.WithError(err).Errorf("json=\n%s", string(resourcesJSON))
-
-				return nil, errors.Wrap(err, "endpoint.ClusterLoadAssignment")
+				return nil, errors.Wrapf(err, "endpoint.ClusterLoadAssignment%s", getResourcePath(k, v))
 			}
 
 			results[k] = &resource
@@ -96,9 +108,7 @@ func YamlToResources(yamlObj []interface{}, outType interface{}) ([]types.Resour
 
 			err = protojson.Unmarshal(resourcesJSON, &resource)
 			if err != nil {
-				log.WithError(err).Errorf("json=\n%s", string(resourcesJSON))
-
-				return nil, errors.Wrap(err, "listener.Listener")
+				return nil, errors.Wrapf(err, "listener.Listener%s", getResourcePath(k, v))
 			}
 
 			results[k] = &resource
@@ -107,9 +117,7 @@ func YamlToResources(yamlObj []interface{}, outType interface{}) ([]types.Resour
 
 			err = protojson.Unmarshal(resourcesJSON, &resource)
 			if err != nil {
-				log.WithError(err).Errorf("json=%s", string(resourcesJSON))
-
-				return nil, errors.Wrap(err, "tls.Secret")
+				return nil, errors.Wrapf(err, "tls.Secret%s", getResourcePath(k, v))
 			}
 
 			results[k] = &resource
@@ -0,0 +1,40 @@
+/*
+Copyright paskal.maksim@gmail.com
+Licensed under the Apache License, Version 2.0 (the "License")
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package snapshotstore
+
+import "time"
+
+// use store in tests, returned function restores previous store.
+func SetStore(s Store) func() {
+	previous := store
+	store = s
+
+	return func() {
+		store = previous
+	}
+}
+
+// wait until background operations of node are done.
+func WaitQueue(nodeID string) {
+	for {
+		queueMutex.Lock()
+		_, running := queue[nodeID]
+		queueMutex.Unlock()
+
+		if !running {
+			return
+		}
+
+		time.Sleep(time.Millisecond)
+	}
+}
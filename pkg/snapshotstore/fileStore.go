@@ -0,0 +1,100 @@
+/*
+Copyright paskal.maksim@gmail.com
+Licensed under the Apache License, Version 2.0 (the "License")
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package snapshotstore
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	fileStoreExt  = ".json.gz"
+	fileStoreMode = 0o600
+	fileStoreDir  = 0o700
+)
+
+// snapshots in local directory, one file per node.
+type fileStore struct {
+	path string
+}
+
+func newFileStore(path string) *fileStore {
+	return &fileStore{path: path}
+}
+
+func (s *fileStore) fileName(nodeID string) string {
+	hash := sha256.Sum256([]byte(nodeID))
+
+	return filepath.Join(s.path, hex.EncodeToString(hash[:])+fileStoreExt)
+}
+
+func (s *fileStore) Save(_ context.Context, nodeID string, data []byte) error {
+	if err := os.MkdirAll(s.path, fileStoreDir); err != nil {
+		return errors.Wrap(err, "os.MkdirAll")
+	}
+
+	// write to temporary file first, snapshot file must not be partially written
+	tmpFile := s.fileName(nodeID) + ".tmp"
+
+	if err := os.WriteFile(tmpFile, data, fileStoreMode); err != nil {
+		return errors.Wrap(err, "os.WriteFile")
+	}
+
+	if err := os.Rename(tmpFile, s.fileName(nodeID)); err != nil {
+		return errors.Wrap(err, "os.Rename")
+	}
+
+	return nil
+}
+
+func (s *fileStore) Delete(_ context.Context, nodeID string) error {
+	if err := os.Remove(s.fileName(nodeID)); err != nil && !os.IsNotExist(err) {
+		return errors.Wrap(err, "os.Remove")
+	}
+
+	return nil
+}
+
+func (s *fileStore) LoadAll(_ context.Context) ([][]byte, error) {
+	files, err := os.ReadDir(s.path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+
+		return nil, errors.Wrap(err, "os.ReadDir")
+	}
+
+	result := make([][]byte, 0, len(files))
+
+	for _, file := range files {
+		if file.IsDir() || !strings.HasSuffix(file.Name(), fileStoreExt) {
+			continue
+		}
+
+		data, err := os.ReadFile(filepath.Join(s.path, file.Name()))
+		if err != nil {
+			return nil, errors.Wrap(err, "os.ReadFile")
+		}
+
+		result = append(result, data)
+	}
+
+	return result, nil
+}
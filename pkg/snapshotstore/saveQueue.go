@@ -0,0 +1,89 @@
+/*
+Copyright paskal.maksim@gmail.com
+Licensed under the Apache License, Version 2.0 (the "License")
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package snapshotstore
+
+import (
+	"context"
+	"sync"
+
+	"github.com/envoyproxy/go-control-plane/pkg/cache/v3"
+	log "github.com/sirupsen/logrus"
+)
+
+// latest operation of node that waits for background worker.
+type queuedOperation struct {
+	snap   cache.ResourceSnapshot
+	delete bool
+}
+
+var (
+	queueMutex sync.Mutex
+	// nodes with running worker, nil operation if nothing is queued
+	queue = make(map[string]*queuedOperation)
+)
+
+// save snapshot in background, only latest snapshot of node is saved.
+func SaveAsync(ctx context.Context, nodeID string, snap cache.ResourceSnapshot) {
+	enqueue(ctx, nodeID, &queuedOperation{snap: snap})
+}
+
+// delete snapshot in background after queued save of node.
+func DeleteAsync(ctx context.Context, nodeID string) {
+	enqueue(ctx, nodeID, &queuedOperation{delete: true})
+}
+
+func enqueue(ctx context.Context, nodeID string, operation *queuedOperation) {
+	if store == nil {
+		return
+	}
+
+	queueMutex.Lock()
+	_, running := queue[nodeID]
+	queue[nodeID] = operation
+	queueMutex.Unlock()
+
+	if !running {
+		// operation must finish when request context is done
+		go runQueue(context.WithoutCancel(ctx), nodeID)
+	}
+}
+
+// one worker for node, operations of node are not reordered.
+func runQueue(ctx context.Context, nodeID string) {
+	for {
+		queueMutex.Lock()
+
+		operation := queue[nodeID]
+		if operation == nil {
+			delete(queue, nodeID)
+			queueMutex.Unlock()
+
+			return
+		}
+
+		queue[nodeID] = nil
+		queueMutex.Unlock()
+
+		if operation.delete {
+			if err := Delete(ctx, nodeID); err != nil {
+				log.WithError(err).Errorf("error deleting snapshot %s", nodeID)
+			}
+
+			continue
+		}
+
+		if err := Save(ctx, nodeID, operation.snap); err != nil {
+			log.WithError(err).Errorf("error saving snapshot %s", nodeID)
+		}
+	}
+}
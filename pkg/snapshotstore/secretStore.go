@@ -0,0 +1,121 @@
+/*
+Copyright paskal.maksim@gmail.com
+Licensed under the Apache License, Version 2.0 (the "License")
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package snapshotstore
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+
+	"github.com/maksim-paskal/envoy-control-plane/pkg/api"
+	"github.com/maksim-paskal/envoy-control-plane/pkg/config"
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	secretKey            = "snapshot.json.gz"
+	secretLabel          = config.AppName + "/snapshot"
+	secretAnnotationNode = config.AppName + "/node-id"
+	secretNameHashLength = 16
+	secretNameInfix      = "-snapshot-"
+)
+
+// snapshots in kubernetes secrets, one secret per node. Only leader writes secrets.
+type secretStore struct {
+	namespace string
+}
+
+func newSecretStore(namespace string) *secretStore {
+	return &secretStore{namespace: namespace}
+}
+
+func (s *secretStore) secretName(nodeID string) string {
+	hash := sha256.Sum256([]byte(nodeID))
+
+	return config.AppName + secretNameInfix + hex.EncodeToString(hash[:])[:secretNameHashLength]
+}
+
+func (s *secretStore) Save(ctx context.Context, nodeID string, data []byte) error {
+	if !api.IsLeader() {
+		return nil
+	}
+
+	secrets := api.Client.KubeClient().CoreV1().Secrets(s.namespace)
+
+	secret, err := secrets.Get(ctx, s.secretName(nodeID), metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		_, err = secrets.Create(ctx, &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:        s.secretName(nodeID),
+				Labels:      map[string]string{secretLabel: "true"},
+				Annotations: map[string]string{secretAnnotationNode: nodeID},
+			},
+			Data: map[string][]byte{secretKey: data},
+		}, metav1.CreateOptions{})
+		if err != nil {
+			return errors.Wrap(err, "error creating secret")
+		}
+
+		return nil
+	}
+
+	if err != nil {
+		return errors.Wrap(err, "error getting secret")
+	}
+
+	if bytes.Equal(secret.Data[secretKey], data) {
+		return nil
+	}
+
+	secret.Data = map[string][]byte{secretKey: data}
+
+	if _, err := secrets.Update(ctx, secret, metav1.UpdateOptions{}); err != nil {
+		return errors.Wrap(err, "error updating secret")
+	}
+
+	return nil
+}
+
+func (s *secretStore) Delete(ctx context.Context, nodeID string) error {
+	if !api.IsLeader() {
+		return nil
+	}
+
+	err := api.Client.KubeClient().CoreV1().Secrets(s.namespace).Delete(ctx, s.secretName(nodeID), metav1.DeleteOptions{}) //nolint:lll
+	if err != nil && !apierrors.IsNotFound(err) {
+		return errors.Wrap(err, "error deleting secret")
+	}
+
+	return nil
+}
+
+func (s *secretStore) LoadAll(ctx context.Context) ([][]byte, error) {
+	secrets, err := api.Client.KubeClient().CoreV1().Secrets(s.namespace).List(ctx, metav1.ListOptions{
+		LabelSelector: secretLabel + "=true",
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "error listing secrets")
+	}
+
+	result := make([][]byte, 0, len(secrets.Items))
+
+	for _, secret := range secrets.Items {
+		result = append(result, secret.Data[secretKey])
+	}
+
+	return result, nil
+}
@@ -0,0 +1,86 @@
+/*
+Copyright paskal.maksim@gmail.com
+Licensed under the Apache License, Version 2.0 (the "License")
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package snapshotstore
+
+import (
+	"crypto/x509"
+	"encoding/pem"
+
+	core "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
+	tls "github.com/envoyproxy/go-control-plane/envoy/extensions/transport_sockets/tls/v3"
+	"github.com/maksim-paskal/envoy-control-plane/pkg/certs"
+	"github.com/pkg/errors"
+)
+
+// secret without private key, nil if secret can not be restored without key material.
+func stripSecret(secret *tls.Secret) *tls.Secret {
+	if secret.GetValidationContext() != nil {
+		return secret
+	}
+
+	cert := secret.GetTlsCertificate()
+	if cert == nil {
+		return nil
+	}
+
+	return &tls.Secret{
+		Name: secret.GetName(),
+		Type: &tls.Secret_TlsCertificate{
+			TlsCertificate: &tls.TlsCertificate{
+				CertificateChain: cert.GetCertificateChain(),
+			},
+		},
+	}
+}
+
+// certificate without private key is generated again with dns names of stored certificate.
+func restoreSecret(secret *tls.Secret) (*tls.Secret, error) {
+	cert := secret.GetTlsCertificate()
+	if cert == nil || cert.GetPrivateKey() != nil {
+		return secret, nil
+	}
+
+	chain := cert.GetCertificateChain().GetInlineBytes()
+	if len(chain) == 0 {
+		chain = []byte(cert.GetCertificateChain().GetInlineString())
+	}
+
+	block, _ := pem.Decode(chain)
+	if block == nil {
+		return nil, errors.Wrap(errNoCertificate, secret.GetName())
+	}
+
+	stored, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		return nil, errors.Wrap(err, "x509.ParseCertificate")
+	}
+
+	_, certBytes, _, keyBytes, err := certs.NewCertificate(stored.DNSNames, certs.CertValidity)
+	if err != nil {
+		return nil, errors.Wrap(err, "certs.NewCertificate")
+	}
+
+	return &tls.Secret{
+		Name: secret.GetName(),
+		Type: &tls.Secret_TlsCertificate{
+			TlsCertificate: &tls.TlsCertificate{
+				CertificateChain: &core.DataSource{
+					Specifier: &core.DataSource_InlineBytes{InlineBytes: certBytes},
+				},
+				PrivateKey: &core.DataSource{
+					Specifier: &core.DataSource_InlineBytes{InlineBytes: keyBytes},
+				},
+			},
+		},
+	}, nil
+}
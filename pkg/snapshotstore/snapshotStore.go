@@ -0,0 +1,228 @@
+/*
+Copyright paskal.maksim@gmail.com
+Licensed under the Apache License, Version 2.0 (the "License")
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package snapshotstore
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"io"
+
+	tls "github.com/envoyproxy/go-control-plane/envoy/extensions/transport_sockets/tls/v3"
+	"github.com/envoyproxy/go-control-plane/pkg/cache/types"
+	"github.com/envoyproxy/go-control-plane/pkg/cache/v3"
+	"github.com/maksim-paskal/envoy-control-plane/pkg/config"
+	"github.com/maksim-paskal/envoy-control-plane/pkg/utils"
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/types/known/anypb"
+)
+
+const (
+	StoreFile   = "file"
+	StoreSecret = "secret"
+)
+
+// backend that saves last pushed snapshots of nodes.
+type Store interface {
+	Save(ctx context.Context, nodeID string, data []byte) error
+	Delete(ctx context.Context, nodeID string) error
+	LoadAll(ctx context.Context) ([][]byte, error)
+}
+
+type storedSnapshot struct {
+	NodeID    string                       `json:"nodeId"`
+	Resources map[string][]json.RawMessage `json:"resources"`
+}
+
+var store Store
+
+func Init() error {
+	switch *config.Get().SnapshotStore {
+	case "":
+		return nil
+	case StoreFile:
+		store = newFileStore(*config.Get().SnapshotStorePath)
+	case StoreSecret:
+		store = newSecretStore(*config.Get().Namespace)
+	default:
+		return errors.Wrap(errUnknownStore, *config.Get().SnapshotStore)
+	}
+
+	log.Infof("using %s snapshot store", *config.Get().SnapshotStore)
+
+	return nil
+}
+
+func Enabled() bool {
+	return store != nil
+}
+
+// save snapshot of node, private keys of secrets are not saved.
+func Save(ctx context.Context, nodeID string, snap cache.ResourceSnapshot) error {
+	if store == nil {
+		return nil
+	}
+
+	data, err := Marshal(nodeID, snap)
+	if err != nil {
+		return err
+	}
+
+	return store.Save(ctx, nodeID, data)
+}
+
+func Delete(ctx context.Context, nodeID string) error {
+	if store == nil {
+		return nil
+	}
+
+	return store.Delete(ctx, nodeID)
+}
+
+// load all saved snapshots by node id.
+func LoadAll(ctx context.Context) (map[string]*cache.Snapshot, error) {
+	result := make(map[string]*cache.Snapshot)
+
+	if store == nil {
+		return result, nil
+	}
+
+	items, err := store.LoadAll(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, data := range items {
+		nodeID, snap, err := Unmarshal(data)
+		if err != nil {
+			log.WithError(err).Error("error loading snapshot")
+
+			continue
+		}
+
+		result[nodeID] = snap
+	}
+
+	return result, nil
+}
+
+// gzipped json with resources of snapshot.
+func Marshal(nodeID string, snap cache.ResourceSnapshot) ([]byte, error) {
+	stored := storedSnapshot{
+		NodeID:    nodeID,
+		Resources: make(map[string][]json.RawMessage),
+	}
+
+	for typ, items := range utils.GetSnapshotResources(snap) {
+		for _, item := range items {
+			// private keys are not persisted
+			if secret, ok := item.(*tls.Secret); ok {
+				stripped := stripSecret(secret)
+				if stripped == nil {
+					continue
+				}
+
+				item = stripped
+			}
+
+			anyResource, err := anypb.New(item)
+			if err != nil {
+				return nil, errors.Wrap(err, "anypb.New")
+			}
+
+			b, err := protojson.Marshal(anyResource)
+			if err != nil {
+				return nil, errors.Wrap(err, "protojson.Marshal")
+			}
+
+			stored.Resources[typ] = append(stored.Resources[typ], b)
+		}
+	}
+
+	b, err := json.Marshal(stored)
+	if err != nil {
+		return nil, errors.Wrap(err, "json.Marshal")
+	}
+
+	var buf bytes.Buffer
+
+	gz := gzip.NewWriter(&buf)
+
+	if _, err := gz.Write(b); err != nil {
+		return nil, errors.Wrap(err, "gzip.Write")
+	}
+
+	if err := gz.Close(); err != nil {
+		return nil, errors.Wrap(err, "gzip.Close")
+	}
+
+	return buf.Bytes(), nil
+}
+
+func Unmarshal(data []byte) (string, *cache.Snapshot, error) {
+	gz, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return "", nil, errors.Wrap(err, "gzip.NewReader")
+	}
+	defer gz.Close()
+
+	b, err := io.ReadAll(gz)
+	if err != nil {
+		return "", nil, errors.Wrap(err, "gzip.Read")
+	}
+
+	stored := storedSnapshot{}
+
+	if err := json.Unmarshal(b, &stored); err != nil {
+		return "", nil, errors.Wrap(err, "json.Unmarshal")
+	}
+
+	if len(stored.NodeID) == 0 {
+		return "", nil, errNoNodeID
+	}
+
+	resources := make(map[string][]types.Resource)
+
+	for typ, items := range stored.Resources {
+		for _, item := range items {
+			anyResource := anypb.Any{}
+
+			if err := protojson.Unmarshal(item, &anyResource); err != nil {
+				return "", nil, errors.Wrap(err, "protojson.Unmarshal")
+			}
+
+			resource, err := anyResource.UnmarshalNew()
+			if err != nil {
+				return "", nil, errors.Wrap(err, "anyResource.UnmarshalNew")
+			}
+
+			if secret, ok := resource.(*tls.Secret); ok {
+				if resource, err = restoreSecret(secret); err != nil {
+					return "", nil, err
+				}
+			}
+
+			resources[typ] = append(resources[typ], resource)
+		}
+	}
+
+	snap, err := utils.NewHashedSnapshot(resources)
+	if err != nil {
+		return "", nil, err
+	}
+
+	return stored.NodeID, snap, nil
+}
@@ -0,0 +1,214 @@
+/*
+Copyright paskal.maksim@gmail.com
+Licensed under the Apache License, Version 2.0 (the "License")
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package snapshotstore_test
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/base64"
+	"io"
+	"sync"
+	"testing"
+
+	cluster "github.com/envoyproxy/go-control-plane/envoy/config/cluster/v3"
+	endpoint "github.com/envoyproxy/go-control-plane/envoy/config/endpoint/v3"
+	tls "github.com/envoyproxy/go-control-plane/envoy/extensions/transport_sockets/tls/v3"
+	"github.com/envoyproxy/go-control-plane/pkg/cache/types"
+	"github.com/envoyproxy/go-control-plane/pkg/resource/v3"
+	"github.com/maksim-paskal/envoy-control-plane/pkg/certs"
+	"github.com/maksim-paskal/envoy-control-plane/pkg/config"
+	"github.com/maksim-paskal/envoy-control-plane/pkg/snapshotstore"
+	"github.com/maksim-paskal/envoy-control-plane/pkg/utils"
+)
+
+func TestMarshalUnmarshal(t *testing.T) {
+	t.Parallel()
+
+	snap, err := utils.NewHashedSnapshot(map[string][]types.Resource{
+		resource.ClusterType:  {&cluster.Cluster{Name: "cluster1"}},
+		resource.EndpointType: {&endpoint.ClusterLoadAssignment{ClusterName: "cluster1"}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := snapshotstore.Marshal("test-id", snap)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	nodeID, restored, err := snapshotstore.Unmarshal(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if want := "test-id"; nodeID != want {
+		t.Fatalf("nodeID != %s", want)
+	}
+
+	// restored snapshot must not be pushed again to envoy
+	if utils.GetSnapshotVersion(snap) != utils.GetSnapshotVersion(restored) {
+		t.Fatal("restored snapshot version must be the same")
+	}
+
+	if _, ok := restored.GetResources(resource.ClusterType)["cluster1"]; !ok {
+		t.Fatal("cluster1 not restored")
+	}
+}
+
+func TestMarshalSecrets(t *testing.T) {
+	t.Parallel()
+
+	if err := config.Load(); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := certs.Init(); err != nil {
+		t.Fatal(err)
+	}
+
+	secrets, err := utils.NewSecrets("test-name", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	resources := make([]types.Resource, 0, len(secrets))
+	for i := range secrets {
+		resources = append(resources, &secrets[i])
+	}
+
+	snap, err := utils.NewHashedSnapshot(map[string][]types.Resource{resource.SecretType: resources})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := snapshotstore.Marshal("test-id", snap)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	key := secrets[0].GetTlsCertificate().GetPrivateKey().GetInlineBytes()
+
+	// protojson encodes bytes in base64
+	if bytes.Contains(gunzip(t, data), []byte(base64.StdEncoding.EncodeToString(key))) {
+		t.Fatal("private key must not be saved")
+	}
+
+	_, restored, err := snapshotstore.Unmarshal(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	restoredSecrets := restored.GetResources(resource.SecretType)
+
+	if len(restoredSecrets) != len(secrets) {
+		t.Fatalf("restored %d secrets, want %d", len(restoredSecrets), len(secrets))
+	}
+
+	cert, ok := restoredSecrets[*config.Get().SSLName].(*tls.Secret)
+	if !ok {
+		t.Fatal("certificate not restored")
+	}
+
+	restoredKey := cert.GetTlsCertificate().GetPrivateKey().GetInlineBytes()
+
+	if len(restoredKey) == 0 || bytes.Equal(restoredKey, key) {
+		t.Fatal("restored certificate must have new private key")
+	}
+}
+
+func gunzip(t *testing.T, data []byte) []byte {
+	t.Helper()
+
+	gz, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	b, err := io.ReadAll(gz)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return b
+}
+
+type memoryStore struct {
+	mutex sync.Mutex
+	data  map[string][]byte
+}
+
+func (s *memoryStore) Save(_ context.Context, nodeID string, data []byte) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.data[nodeID] = data
+
+	return nil
+}
+
+func (s *memoryStore) Delete(_ context.Context, nodeID string) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	delete(s.data, nodeID)
+
+	return nil
+}
+
+func (s *memoryStore) LoadAll(_ context.Context) ([][]byte, error) {
+	return nil, nil
+}
+
+func TestSaveAsync(t *testing.T) {
+	t.Parallel()
+
+	store := &memoryStore{data: make(map[string][]byte)}
+
+	defer snapshotstore.SetStore(store)()
+
+	ctx := context.Background()
+	versions := make([]string, 0)
+
+	for _, name := range []string{"cluster1", "cluster2", "cluster3"} {
+		snap, err := utils.NewHashedSnapshot(map[string][]types.Resource{
+			resource.ClusterType: {&cluster.Cluster{Name: name}},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		versions = append(versions, utils.GetSnapshotVersion(snap))
+
+		snapshotstore.SaveAsync(ctx, "async-test-id", snap)
+	}
+
+	snapshotstore.WaitQueue("async-test-id")
+
+	_, restored, err := snapshotstore.Unmarshal(store.data["async-test-id"])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if utils.GetSnapshotVersion(restored) != versions[len(versions)-1] {
+		t.Fatal("latest snapshot must be saved")
+	}
+
+	snapshotstore.DeleteAsync(ctx, "async-test-id")
+	snapshotstore.WaitQueue("async-test-id")
+
+	if _, ok := store.data["async-test-id"]; ok {
+		t.Fatal("snapshot must be deleted")
+	}
+}
@@ -0,0 +1,136 @@
+/*
+Copyright paskal.maksim@gmail.com
+Licensed under the Apache License, Version 2.0 (the "License")
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package utils
+
+import (
+	"encoding/json"
+	"regexp"
+
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v3"
+)
+
+const Redacted = "[redacted]"
+
+var (
+	// fields with secret data sources, in yaml/proto json/go json names
+	redactKeys = map[string]bool{
+		"private_key":         true,
+		"privateKey":          true,
+		"password":            true,
+		"pkcs12":              true,
+		"secret":              true,
+		"session_ticket_keys": true,
+		"sessionTicketKeys":   true,
+	}
+	// inline values of data source
+	redactInlineKeys = map[string]bool{
+		"inline_string": true,
+		"inlineString":  true,
+		"InlineString":  true,
+		"inline_bytes":  true,
+		"inlineBytes":   true,
+		"InlineBytes":   true,
+	}
+	redactPrivateKey = regexp.MustCompile(`-----BEGIN [A-Z ]*PRIVATE KEY-----[\s\S]*?-----END [A-Z ]*PRIVATE KEY-----`)
+)
+
+// value that can be converted to json with masked private keys and inline secrets.
+func RedactJSON(value interface{}) (interface{}, error) {
+	b, err := json.Marshal(value)
+	if err != nil {
+		return nil, errors.Wrap(err, "json.Marshal")
+	}
+
+	var result interface{}
+
+	if err := json.Unmarshal(b, &result); err != nil {
+		return nil, errors.Wrap(err, "json.Unmarshal")
+	}
+
+	return redactValue(result, false), nil
+}
+
+// json document with masked private keys and inline secrets.
+func RedactJSONBytes(data []byte) ([]byte, error) {
+	var value interface{}
+
+	if err := json.Unmarshal(data, &value); err != nil {
+		return nil, errors.Wrap(err, "json.Unmarshal")
+	}
+
+	b, err := json.Marshal(redactValue(value, false))
+	if err != nil {
+		return nil, errors.Wrap(err, "json.Marshal")
+	}
+
+	return b, nil
+}
+
+// yaml of value with masked private keys and inline secrets.
+func RedactYAML(value interface{}) ([]byte, error) {
+	b, err := yaml.Marshal(value)
+	if err != nil {
+		return nil, errors.Wrap(err, "yaml.Marshal")
+	}
+
+	var result interface{}
+
+	if err := yaml.Unmarshal(b, &result); err != nil {
+		return nil, errors.Wrap(err, "yaml.Unmarshal")
+	}
+
+	b, err = yaml.Marshal(redactValue(result, false))
+	if err != nil {
+		return nil, errors.Wrap(err, "yaml.Marshal")
+	}
+
+	return b, nil
+}
+
+// mask PEM private keys in text, for example in config templates.
+func RedactString(value string) string {
+	return redactPrivateKey.ReplaceAllString(value, Redacted)
+}
+
+func redactValue(value interface{}, isSecret bool) interface{} {
+	switch typed := value.(type) {
+	case map[string]interface{}:
+		for key, item := range typed {
+			switch {
+			case isSecret && redactInlineKeys[key]:
+				typed[key] = Redacted
+			case redactKeys[key]:
+				if _, ok := item.(string); ok {
+					typed[key] = Redacted
+				} else {
+					typed[key] = redactValue(item, true)
+				}
+			default:
+				typed[key] = redactValue(item, isSecret)
+			}
+		}
+
+		return typed
+	case []interface{}:
+		for i, item := range typed {
+			typed[i] = redactValue(item, isSecret)
+		}
+
+		return typed
+	case string:
+		return RedactString(typed)
+	default:
+		return value
+	}
+}
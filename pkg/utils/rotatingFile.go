@@ -0,0 +1,191 @@
+/*
+Copyright paskal.maksim@gmail.com
+Licensed under the Apache License, Version 2.0 (the "License")
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package utils
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+)
+
+const rotatingFilePerm = 0o644
+
+// file that is renamed to file.1 when reaches max size, file.1 to file.2 and so on.
+type RotatingFile struct {
+	mutex      sync.Mutex
+	path       string
+	maxSize    int64
+	maxBackups int
+	maxAge     time.Duration
+	file       *os.File
+	size       int64
+}
+
+// maxSize in bytes, 0 disables rotation, backups above maxBackups or older than maxAge are deleted.
+func NewRotatingFile(path string, maxSize int64, maxBackups int, maxAge time.Duration) (*RotatingFile, error) {
+	f := &RotatingFile{
+		path:       path,
+		maxSize:    maxSize,
+		maxBackups: maxBackups,
+		maxAge:     maxAge,
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil { //nolint:gomnd
+		return nil, errors.Wrap(err, "error creating directory")
+	}
+
+	if err := f.open(); err != nil {
+		return nil, err
+	}
+
+	f.removeExpired()
+
+	return f, nil
+}
+
+func (f *RotatingFile) open() error {
+	file, err := os.OpenFile(f.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, rotatingFilePerm)
+	if err != nil {
+		return errors.Wrap(err, "error opening file")
+	}
+
+	info, err := file.Stat()
+	if err != nil {
+		_ = file.Close()
+
+		return errors.Wrap(err, "error in file.Stat")
+	}
+
+	f.file = file
+	f.size = info.Size()
+
+	return nil
+}
+
+func (f *RotatingFile) Write(p []byte) (int, error) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	if f.file == nil {
+		return 0, os.ErrClosed
+	}
+
+	if f.maxSize > 0 && f.size > 0 && f.size+int64(len(p)) > f.maxSize {
+		if err := f.rotate(); err != nil {
+			if f.file == nil {
+				return 0, err
+			}
+
+			// file was not rotated, write to current file
+			log.WithError(err).Warnf("error rotating %s", f.path)
+		}
+	}
+
+	n, err := f.file.Write(p)
+	f.size += int64(n)
+
+	if err != nil {
+		return n, errors.Wrap(err, "error writing file")
+	}
+
+	return n, nil
+}
+
+func (f *RotatingFile) backupName(index int) string {
+	return fmt.Sprintf("%s.%d", f.path, index)
+}
+
+// current file is reopened on any rotation error.
+func (f *RotatingFile) rotate() error {
+	err := f.file.Close()
+	if err != nil {
+		err = errors.Wrap(err, "error closing file")
+	}
+
+	f.file = nil
+
+	if err == nil {
+		err = f.moveBackups()
+	}
+
+	if err != nil {
+		if openErr := f.open(); openErr != nil {
+			return errors.Wrap(openErr, err.Error())
+		}
+
+		return err
+	}
+
+	f.removeExpired()
+
+	return f.open()
+}
+
+func (f *RotatingFile) moveBackups() error {
+	if f.maxBackups > 0 {
+		_ = os.Remove(f.backupName(f.maxBackups))
+
+		for i := f.maxBackups - 1; i > 0; i-- {
+			_ = os.Rename(f.backupName(i), f.backupName(i+1))
+		}
+
+		if err := os.Rename(f.path, f.backupName(1)); err != nil {
+			return errors.Wrap(err, "error renaming file")
+		}
+	} else if err := os.Remove(f.path); err != nil {
+		return errors.Wrap(err, "error removing file")
+	}
+
+	return nil
+}
+
+// remove backups older than max age.
+func (f *RotatingFile) removeExpired() {
+	if f.maxAge <= 0 {
+		return
+	}
+
+	for i := 1; i <= f.maxBackups; i++ {
+		info, err := os.Stat(f.backupName(i))
+		if err != nil {
+			continue
+		}
+
+		if time.Since(info.ModTime()) > f.maxAge {
+			_ = os.Remove(f.backupName(i))
+		}
+	}
+}
+
+func (f *RotatingFile) Close() error {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	if f.file == nil {
+		return nil
+	}
+
+	err := f.file.Close()
+	f.file = nil
+
+	if err != nil {
+		return errors.Wrap(err, "error closing file")
+	}
+
+	return nil
+}
@@ -0,0 +1,192 @@
+/*
+Copyright paskal.maksim@gmail.com
+Licensed under the Apache License, Version 2.0 (the "License")
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package utils
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+
+	"github.com/envoyproxy/go-control-plane/pkg/cache/types"
+	"github.com/envoyproxy/go-control-plane/pkg/cache/v3"
+	resource "github.com/envoyproxy/go-control-plane/pkg/resource/v3"
+	"github.com/pkg/errors"
+	"google.golang.org/protobuf/encoding/protojson"
+)
+
+const (
+	ResourceAdded   = "added"
+	ResourceRemoved = "removed"
+	ResourceChanged = "changed"
+)
+
+// changed field of resource, path is like filter_chains[0].filters[0].name.
+type FieldDiff struct {
+	Path      string
+	Current   interface{} `json:",omitempty"`
+	Candidate interface{} `json:",omitempty"`
+}
+
+type ResourceDiff struct {
+	Type   string
+	Name   string
+	Action string
+	Fields []*FieldDiff `json:",omitempty"`
+}
+
+// resources that will be added, removed or changed if candidate snapshot replaces current,
+// current snapshot can be nil, values of secrets are not returned.
+func DiffSnapshots(current, candidate cache.ResourceSnapshot) ([]*ResourceDiff, error) {
+	result := make([]*ResourceDiff, 0)
+
+	for _, typ := range snapshotTypes {
+		currentResources := make(map[string]types.Resource)
+		if current != nil {
+			currentResources = current.GetResources(typ)
+		}
+
+		candidateResources := candidate.GetResources(typ)
+
+		names := make([]string, 0, len(currentResources)+len(candidateResources))
+
+		for name := range currentResources {
+			names = append(names, name)
+		}
+
+		for name := range candidateResources {
+			if _, ok := currentResources[name]; !ok {
+				names = append(names, name)
+			}
+		}
+
+		sort.Strings(names)
+
+		for _, name := range names {
+			diff, err := diffResource(typ, name, currentResources[name], candidateResources[name])
+			if err != nil {
+				return nil, err
+			}
+
+			if diff != nil {
+				result = append(result, diff)
+			}
+		}
+	}
+
+	return result, nil
+}
+
+func diffResource(typ, name string, current, candidate types.Resource) (*ResourceDiff, error) {
+	diff := &ResourceDiff{
+		Type: typ,
+		Name: name,
+	}
+
+	currentFields, err := getResourceFields(current)
+	if err != nil {
+		return nil, errors.Wrapf(err, "error in getResourceFields %s %s", typ, name)
+	}
+
+	candidateFields, err := getResourceFields(candidate)
+	if err != nil {
+		return nil, errors.Wrapf(err, "error in getResourceFields %s %s", typ, name)
+	}
+
+	switch {
+	case current == nil:
+		diff.Action = ResourceAdded
+	case candidate == nil:
+		diff.Action = ResourceRemoved
+	default:
+		diff.Action = ResourceChanged
+	}
+
+	paths := make([]string, 0, len(currentFields)+len(candidateFields))
+
+	for path := range currentFields {
+		paths = append(paths, path)
+	}
+
+	for path := range candidateFields {
+		if _, ok := currentFields[path]; !ok {
+			paths = append(paths, path)
+		}
+	}
+
+	sort.Strings(paths)
+
+	for _, path := range paths {
+		if reflect.DeepEqual(currentFields[path], candidateFields[path]) {
+			continue
+		}
+
+		fieldDiff := &FieldDiff{Path: path}
+
+		if typ != resource.SecretType {
+			fieldDiff.Current = currentFields[path]
+			fieldDiff.Candidate = candidateFields[path]
+		}
+
+		diff.Fields = append(diff.Fields, fieldDiff)
+	}
+
+	if diff.Action == ResourceChanged && len(diff.Fields) == 0 {
+		return nil, nil //nolint:nilnil
+	}
+
+	return diff, nil
+}
+
+// protojson values of resource by field path.
+func getResourceFields(item types.Resource) (map[string]interface{}, error) {
+	result := make(map[string]interface{})
+
+	if item == nil {
+		return result, nil
+	}
+
+	itemJSON, err := protojson.Marshal(item)
+	if err != nil {
+		return nil, errors.Wrap(err, "protojson.Marshal")
+	}
+
+	var value interface{}
+
+	if err := json.Unmarshal(itemJSON, &value); err != nil {
+		return nil, errors.Wrap(err, "json.Unmarshal")
+	}
+
+	flattenFields("", redactValue(value, false), result)
+
+	return result, nil
+}
+
+func flattenFields(path string, value interface{}, result map[string]interface{}) {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for key, item := range v {
+			if len(path) == 0 {
+				flattenFields(key, item, result)
+			} else {
+				flattenFields(path+"."+key, item, result)
+			}
+		}
+	case []interface{}:
+		for i, item := range v {
+			flattenFields(fmt.Sprintf("%s[%d]", path, i), item, result)
+		}
+	default:
+		result[path] = v
+	}
+}
@@ -13,8 +13,11 @@ limitations under the License.
 package utils
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
+	"sort"
 	"time"
 
 	core "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
@@ -32,6 +35,79 @@ import (
 )
 
 func GetConfigSnapshot(version string, configType *config.ConfigType, endpoints []types.Resource, commonSecrets []tls.Secret) (*cache.Snapshot, error) { //nolint: lll
+	return cache.NewSnapshot(version, getConfigResources(configType, endpoints, commonSecrets))
+}
+
+// snapshot where version of every resource type is a hash of resources content,
+// envoy will receive only resource types that were changed.
+func GetHashedConfigSnapshot(configType *config.ConfigType, endpoints []types.Resource, commonSecrets []tls.Secret) (*cache.Snapshot, error) { //nolint: lll
+	return NewHashedSnapshot(getConfigResources(configType, endpoints, commonSecrets))
+}
+
+// snapshot from resources by type, versions are hashes of resources content.
+func NewHashedSnapshot(resources map[string][]types.Resource) (*cache.Snapshot, error) {
+	snap := cache.Snapshot{}
+
+	for typ, items := range resources {
+		version, err := GetResourcesVersion(items)
+		if err != nil {
+			return nil, errors.Wrapf(err, "error in GetResourcesVersion %s", typ)
+		}
+
+		snap.Resources[cache.GetResponseType(typ)] = cache.NewResources(version, items)
+	}
+
+	return &snap, nil
+}
+
+// all resources in snapshot by type, sorted by name.
+func GetSnapshotResources(snap cache.ResourceSnapshot) map[string][]types.Resource {
+	result := make(map[string][]types.Resource, len(snapshotTypes))
+
+	for _, typ := range snapshotTypes {
+		resources := snap.GetResources(typ)
+
+		names := make([]string, 0, len(resources))
+		for name := range resources {
+			names = append(names, name)
+		}
+
+		sort.Strings(names)
+
+		items := make([]types.Resource, 0, len(names))
+		for _, name := range names {
+			items = append(items, resources[name])
+		}
+
+		result[typ] = items
+	}
+
+	return result
+}
+
+type validator interface {
+	Validate() error
+}
+
+// validate all resources in snapshot with envoy protobuf rules.
+func ValidateSnapshot(snap *cache.Snapshot) error {
+	for _, typ := range snapshotTypes {
+		for name, item := range snap.GetResourcesAndTTL(typ) {
+			v, ok := item.Resource.(validator)
+			if !ok {
+				continue
+			}
+
+			if err := v.Validate(); err != nil {
+				return errors.Wrapf(err, "invalid resource %s %s", typ, name)
+			}
+		}
+	}
+
+	return nil
+}
+
+func getConfigResources(configType *config.ConfigType, endpoints []types.Resource, commonSecrets []tls.Secret) map[string][]types.Resource { //nolint: lll
 	secrets := configType.GetSecrets()
 	for i := range commonSecrets {
 		secrets = append(secrets, &commonSecrets[i])
@@ -45,7 +121,104 @@ func GetConfigSnapshot(version string, configType *config.ConfigType, endpoints
 	resources[resource.SecretType] = secrets
 	resources[resource.EndpointType] = endpoints
 
-	return cache.NewSnapshot(version, resources)
+	return resources
+}
+
+// hash of deterministic protobuf representation of resource.
+func GetResourceHash(r types.Resource) (string, error) {
+	marshaled, err := cache.MarshalResource(r)
+	if err != nil {
+		return "", errors.Wrap(err, "error in cache.MarshalResource")
+	}
+
+	return cache.HashResource(marshaled), nil
+}
+
+// version of resources group, not depends on resources order.
+func GetResourcesVersion(resources []types.Resource) (string, error) {
+	hashes := make([]string, 0, len(resources))
+
+	for _, r := range resources {
+		hash, err := GetResourceHash(r)
+		if err != nil {
+			return "", err
+		}
+
+		hashes = append(hashes, cache.GetResourceName(r)+"|"+hash)
+	}
+
+	sort.Strings(hashes)
+
+	return hashStrings(hashes), nil
+}
+
+// resource types that control-plane serves.
+var snapshotTypes = []string{
+	resource.ClusterType,
+	resource.RouteType,
+	resource.ListenerType,
+	resource.SecretType,
+	resource.EndpointType,
+}
+
+// version of all resource types in snapshot.
+func GetSnapshotVersion(snap cache.ResourceSnapshot) string {
+	versions := make([]string, 0, len(snapshotTypes))
+
+	for _, typ := range snapshotTypes {
+		versions = append(versions, snap.GetVersion(typ))
+	}
+
+	return hashStrings(versions)
+}
+
+// hashes of all resources in snapshot by resource type and name.
+func GetSnapshotHashes(snap cache.ResourceSnapshot) (map[string]map[string]string, error) {
+	result := make(map[string]map[string]string, len(snapshotTypes))
+
+	for _, typ := range snapshotTypes {
+		hashes := make(map[string]string)
+
+		for name, r := range snap.GetResources(typ) {
+			hash, err := GetResourceHash(r)
+			if err != nil {
+				return nil, err
+			}
+
+			hashes[name] = hash
+		}
+
+		result[typ] = hashes
+	}
+
+	return result, nil
+}
+
+// hash of values that can be converted to json, json.Marshal sorts map keys.
+func GetJSONHash(values ...interface{}) (string, error) {
+	hashes := make([]string, 0, len(values))
+
+	for _, value := range values {
+		b, err := json.Marshal(utils.ConvertYAMLtoJSON(value))
+		if err != nil {
+			return "", errors.Wrap(err, "json.Marshal")
+		}
+
+		hashes = append(hashes, string(b))
+	}
+
+	return hashStrings(hashes), nil
+}
+
+func hashStrings(values []string) string {
+	hasher := sha256.New()
+
+	for _, value := range values {
+		hasher.Write([]byte(value))
+		hasher.Write([]byte{'\n'})
+	}
+
+	return hex.EncodeToString(hasher.Sum(nil))
 }
 
 func NewSecrets(dnsName string, validation interface{}) ([]tls.Secret, error) {
@@ -23,19 +23,25 @@ import (
 	"net/http/pprof"
 	"os"
 	"runtime"
+	"strconv"
 	"strings"
 	"time"
 
 	"github.com/envoyproxy/go-control-plane/pkg/cache/v3"
+	"github.com/maksim-paskal/envoy-control-plane/pkg/accesslog"
 	"github.com/maksim-paskal/envoy-control-plane/pkg/api"
 	"github.com/maksim-paskal/envoy-control-plane/pkg/certs"
 	"github.com/maksim-paskal/envoy-control-plane/pkg/config"
+	"github.com/maksim-paskal/envoy-control-plane/pkg/configmapsstore"
 	"github.com/maksim-paskal/envoy-control-plane/pkg/configstore"
 	"github.com/maksim-paskal/envoy-control-plane/pkg/controlplane"
 	"github.com/maksim-paskal/envoy-control-plane/pkg/metrics"
+	"github.com/maksim-paskal/envoy-control-plane/pkg/utils"
 	logrushooksentry "github.com/maksim-paskal/logrus-hook-sentry"
 	"github.com/pkg/errors"
 	log "github.com/sirupsen/logrus"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/yaml"
 )
 
 const (
@@ -44,6 +50,10 @@ const (
 	serverReadTimeout    = 5 * time.Second
 	serverRequestTimeout = 5 * time.Second
 	serverWriteTimeout   = 10 * time.Second
+	maxConfigMapSize     = 1024 * 1024
+	tailDefaultLimit     = 100
+	tailBuffer           = 1000
+	tailKeepAlive        = 15 * time.Second
 )
 
 var timeoutMessage = fmt.Sprintf("Server timeout after %s", serverRequestTimeout)
@@ -54,6 +64,8 @@ type Route struct {
 	handlerFunc func(w http.ResponseWriter, r *http.Request)
 	handler     http.Handler
 	httpShema   bool
+	// streaming response without server timeout
+	stream bool
 }
 
 func getRoutes() []Route {
@@ -76,12 +88,23 @@ func getRoutes() []Route {
 	})
 	routes = append(routes, Route{
 		path:        "/api/admin/status",
-		description: "Status all nodes in SnapshotCache ",
+		description: "Status all nodes in SnapshotCache, secrets are masked unless redact=false",
 		handlerFunc: handlerStatus,
 	})
+	routes = append(routes, Route{
+		path:        "/api/admin/nodes",
+		description: "Connected envoys, filter by node id with id",
+		handlerFunc: handlerNodes,
+	})
+	routes = append(routes, Route{
+		path:        "/api/admin/accesslog/tail",
+		description: "Recent and new envoy access logs, filter by node, log, authority, path, code, cluster and request_id",
+		handlerFunc: handlerAccessLogTail,
+		stream:      true,
+	})
 	routes = append(routes, Route{
 		path:        "/api/admin/config_dump",
-		description: "All dumps of configs that loaded to control-plane",
+		description: "All dumps of configs that loaded to control-plane, secrets are masked unless redact=false",
 		handlerFunc: handlerConfigDump,
 	})
 	routes = append(routes, Route{
@@ -105,6 +128,31 @@ func getRoutes() []Route {
 		description: "Get metrics",
 		handler:     metrics.GetHandler(),
 	})
+	routes = append(routes, Route{
+		path:        "/api/admin/dry_run",
+		description: "Changes of ConfigMap in request body, POST only",
+		handlerFunc: handlerDryRun,
+	})
+	routes = append(routes, Route{
+		path:        "/api/admin/history",
+		description: "Pushed snapshots of node",
+		handlerFunc: handlerHistory,
+	})
+	routes = append(routes, Route{
+		path:        "/api/admin/history/diff",
+		description: "Changes between snapshots in history, from and to versions, latest if empty",
+		handlerFunc: handlerHistoryDiff,
+	})
+	routes = append(routes, Route{
+		path:        "/api/admin/history/pin",
+		description: "Serve snapshot from history until unpin, POST only",
+		handlerFunc: handlerHistoryPin,
+	})
+	routes = append(routes, Route{
+		path:        "/api/admin/history/unpin",
+		description: "Serve latest snapshot, POST only",
+		handlerFunc: handlerHistoryUnpin,
+	})
 	routes = append(routes, Route{
 		path:        "/api/admin/certs",
 		description: "Generate cert",
@@ -141,7 +189,7 @@ func Start(ctx context.Context) {
 
 	server := &http.Server{
 		Addr:         *config.Get().WebHTTPAddress,
-		Handler:      http.TimeoutHandler(auth(GetHandler(true)), serverRequestTimeout, timeoutMessage),
+		Handler:      auth(GetHandler(true)),
 		ReadTimeout:  serverReadTimeout,
 		WriteTimeout: serverWriteTimeout,
 	}
@@ -182,7 +230,7 @@ func StartTLS(ctx context.Context) {
 	server := http.Server{
 		Addr:         *config.Get().WebHTTPSAddress,
 		TLSConfig:    tlsConfig,
-		Handler:      http.TimeoutHandler(auth(GetHandler(false)), serverRequestTimeout, timeoutMessage),
+		Handler:      auth(GetHandler(false)),
 		ReadTimeout:  serverReadTimeout,
 		WriteTimeout: serverWriteTimeout,
 	}
@@ -207,11 +255,16 @@ func GetHandler(onlyHTTPShema bool) *http.ServeMux {
 	for _, route := range getRoutes() {
 		// add only routes with httpShema if onlyHttpShema
 		if !onlyHTTPShema || route.httpShema == onlyHTTPShema {
-			if route.handler != nil {
-				mux.Handle(route.path, route.handler)
-			} else {
-				mux.HandleFunc(route.path, route.handlerFunc)
+			handler := route.handler
+			if handler == nil {
+				handler = http.HandlerFunc(route.handlerFunc)
+			}
+
+			if !route.stream {
+				handler = http.TimeoutHandler(handler, serverRequestTimeout, timeoutMessage)
 			}
+
+			mux.Handle(route.path, handler)
 		}
 	}
 
@@ -313,7 +366,14 @@ func handlerConfigDump(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	b, err := json.MarshalIndent(results, "", " ")
+	redacted, err := redactResponse(r, results)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+
+		return
+	}
+
+	b, err := json.MarshalIndent(redacted, "", " ")
 	if err != nil {
 		log.WithFields(logrushooksentry.AddRequest(r)).WithError(err).Error()
 	}
@@ -380,11 +440,18 @@ func handlerStatus(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 
 	type StatusResponce struct {
-		NodeID   string
+		NodeID      string
+		Version     string
+		Hashes      map[string]map[string]string
+		ConfigError *configstore.ConfigError `json:",omitempty"`
+		Pinned      string                   `json:",omitempty"`
+		// ACK/NACK states by envoy node id, type url and stream
+		Acks     map[string]map[string][]controlplane.AckState `json:",omitempty"`
 		Snapshot cache.ResourceSnapshot
 	}
 
 	statusKeys := controlplane.SnapshotCache.GetStatusKeys()
+	configErrors := configstore.GetConfigErrors()
 
 	results := []StatusResponce{}
 
@@ -398,10 +465,40 @@ func handlerStatus(w http.ResponseWriter, r *http.Request) {
 			log.WithFields(logrushooksentry.AddRequest(r)).WithError(err).Error()
 		}
 
+		if len(id) == 0 || id == nodeID {
+			status := StatusResponce{
+				NodeID:      nodeID,
+				ConfigError: configErrors[nodeID],
+				Snapshot:    sn,
+			}
+
+			_, status.Pinned = configstore.GetHistory(nodeID)
+
+			if acks := controlplane.GetAckStates(nodeID); len(acks) > 0 {
+				status.Acks = acks
+			}
+
+			if sn != nil {
+				status.Version = utils.GetSnapshotVersion(sn)
+
+				status.Hashes, err = utils.GetSnapshotHashes(sn)
+				if err != nil {
+					log.WithFields(logrushooksentry.AddRequest(r)).WithError(err).Error()
+				}
+			}
+
+			results = append(results, status)
+		}
+
+		delete(configErrors, nodeID)
+	}
+
+	// rejected configs of nodes without snapshot
+	for nodeID, configError := range configErrors {
 		if len(id) == 0 || id == nodeID {
 			results = append(results, StatusResponce{
-				NodeID:   nodeID,
-				Snapshot: sn,
+				NodeID:      nodeID,
+				ConfigError: configError,
 			})
 		}
 	}
@@ -412,7 +509,38 @@ func handlerStatus(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	b, err := json.MarshalIndent(results, "", " ")
+	redacted, err := redactResponse(r, results)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+
+		return
+	}
+
+	b, err := json.MarshalIndent(redacted, "", " ")
+	if err != nil {
+		log.WithFields(logrushooksentry.AddRequest(r)).WithError(err).Error()
+	}
+
+	_, err = w.Write(b)
+	if err != nil {
+		log.WithFields(logrushooksentry.AddRequest(r)).WithError(err).Error()
+	}
+}
+
+func handlerNodes(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	id := r.URL.Query().Get("id")
+
+	result := make([]*controlplane.StreamInfo, 0)
+
+	for _, stream := range controlplane.GetStreams() {
+		if len(id) == 0 || stream.NodeID == id {
+			result = append(result, stream)
+		}
+	}
+
+	b, err := json.MarshalIndent(result, "", " ")
 	if err != nil {
 		log.WithFields(logrushooksentry.AddRequest(r)).WithError(err).Error()
 	}
@@ -423,6 +551,131 @@ func handlerStatus(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+// server-sent events with recent access logs and new access logs if follow is not false.
+func handlerAccessLogTail(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+
+	filter := &accesslog.Filter{
+		NodeID:          query.Get("node"),
+		LogName:         query.Get("log"),
+		Authority:       query.Get("authority"),
+		PathPrefix:      query.Get("path"),
+		UpstreamCluster: query.Get("cluster"),
+		RequestID:       query.Get("request_id"),
+	}
+
+	var err error
+
+	if filter.CodeMin, filter.CodeMax, err = accesslog.ParseCodeRange(query.Get("code")); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+
+		return
+	}
+
+	limit := tailDefaultLimit
+
+	if value := query.Get("limit"); len(value) > 0 {
+		if limit, err = strconv.Atoi(value); err != nil {
+			http.Error(w, "limit must be number", http.StatusBadRequest)
+
+			return
+		}
+	}
+
+	follow := query.Get("follow") != "false"
+
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming is not supported", http.StatusInternalServerError)
+
+		return
+	}
+
+	// subscribe before reading recent entries to not lose new entries
+	var entries <-chan *accesslog.Entry
+
+	if follow {
+		subscribed, cancel := accesslog.Subscribe(filter, tailBuffer)
+		defer cancel()
+
+		entries = subscribed
+
+		// stream is open until client disconnects
+		_ = http.NewResponseController(w).SetWriteDeadline(time.Time{})
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+
+	writeEntry := func(entry *accesslog.Entry) error {
+		record, err := accesslog.NewRecord(entry, nil)
+		if err != nil {
+			return err
+		}
+
+		b, err := json.Marshal(record)
+		if err != nil {
+			return errors.Wrap(err, "error in json.Marshal")
+		}
+
+		if _, err = fmt.Fprintf(w, "data: %s\n\n", b); err != nil {
+			return errors.Wrap(err, "error writing entry")
+		}
+
+		return nil
+	}
+
+	for _, entry := range accesslog.GetRecent(filter, limit) {
+		if err := writeEntry(entry); err != nil {
+			log.WithFields(logrushooksentry.AddRequest(r)).WithError(err).Error()
+
+			return
+		}
+	}
+
+	flusher.Flush()
+
+	if !follow {
+		return
+	}
+
+	keepAlive := time.NewTicker(tailKeepAlive)
+	defer keepAlive.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-keepAlive.C:
+			if _, err := w.Write([]byte(": keepalive\n\n")); err != nil {
+				return
+			}
+		case entry := <-entries:
+			if err := writeEntry(entry); err != nil {
+				return
+			}
+		}
+
+		flusher.Flush()
+	}
+}
+
+// private keys and inline secrets are masked unless admin requested redact=false.
+func redactResponse(r *http.Request, value interface{}) (interface{}, error) {
+	if r.Form.Get("redact") == "false" {
+		log.WithFields(logrushooksentry.AddRequest(r)).Warn("response with secrets requested")
+
+		return value, nil
+	}
+
+	redacted, err := utils.RedactJSON(value)
+	if err != nil {
+		return nil, errors.Wrap(err, "error redacting response")
+	}
+
+	return redacted, nil
+}
+
 func handlerZone(w http.ResponseWriter, r *http.Request) {
 	err := r.ParseForm()
 	if err != nil {
@@ -518,3 +771,175 @@ func handlerCerts(w http.ResponseWriter, r *http.Request) {
 	_, _ = w.Write(crtBytes)
 	_, _ = w.Write(keyBytes)
 }
+
+func handlerDryRun(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	if r.Method != http.MethodPost {
+		http.Error(w, "only POST allowed", http.StatusMethodNotAllowed)
+
+		return
+	}
+
+	cm := v1.ConfigMap{}
+
+	decoder := yaml.NewYAMLOrJSONDecoder(http.MaxBytesReader(w, r.Body, maxConfigMapSize), maxConfigMapSize)
+
+	if err := decoder.Decode(&cm); err != nil {
+		http.Error(w, errors.Wrap(err, "error in parsing ConfigMap").Error(), http.StatusBadRequest)
+
+		return
+	}
+
+	if len(cm.Namespace) == 0 {
+		cm.Namespace = r.URL.Query().Get("namespace")
+	}
+
+	results, err := configmapsstore.DryRunConfigMap(&cm)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+
+		return
+	}
+
+	b, err := json.MarshalIndent(results, "", " ")
+	if err != nil {
+		log.WithFields(logrushooksentry.AddRequest(r)).WithError(err).Error()
+	}
+
+	_, err = w.Write(b)
+	if err != nil {
+		log.WithFields(logrushooksentry.AddRequest(r)).WithError(err).Error()
+	}
+}
+
+func handlerHistory(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	type HistoryResponce struct {
+		NodeID  string
+		Pinned  string `json:",omitempty"`
+		History []*configstore.HistoryEntry
+	}
+
+	id := r.URL.Query().Get("id")
+
+	if len(id) == 0 {
+		http.Error(w, "no id", http.StatusBadRequest)
+
+		return
+	}
+
+	entries, pinned := configstore.GetHistory(id)
+	if len(entries) == 0 {
+		http.Error(w, "no results", http.StatusNotFound)
+
+		return
+	}
+
+	b, err := json.MarshalIndent(HistoryResponce{
+		NodeID:  id,
+		Pinned:  pinned,
+		History: entries,
+	}, "", " ")
+	if err != nil {
+		log.WithFields(logrushooksentry.AddRequest(r)).WithError(err).Error()
+	}
+
+	_, err = w.Write(b)
+	if err != nil {
+		log.WithFields(logrushooksentry.AddRequest(r)).WithError(err).Error()
+	}
+}
+
+func handlerHistoryDiff(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	id := r.URL.Query().Get("id")
+
+	if len(id) == 0 {
+		http.Error(w, "no id", http.StatusBadRequest)
+
+		return
+	}
+
+	from, err := configstore.GetHistorySnapshot(id, r.URL.Query().Get("from"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+
+		return
+	}
+
+	to, err := configstore.GetHistorySnapshot(id, r.URL.Query().Get("to"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+
+		return
+	}
+
+	changes, err := utils.DiffSnapshots(from, to)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+
+		return
+	}
+
+	b, err := json.MarshalIndent(changes, "", " ")
+	if err != nil {
+		log.WithFields(logrushooksentry.AddRequest(r)).WithError(err).Error()
+	}
+
+	_, err = w.Write(b)
+	if err != nil {
+		log.WithFields(logrushooksentry.AddRequest(r)).WithError(err).Error()
+	}
+}
+
+func handlerHistoryPin(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "only POST allowed", http.StatusMethodNotAllowed)
+
+		return
+	}
+
+	id := r.URL.Query().Get("id")
+	version := r.URL.Query().Get("version")
+
+	if len(id) == 0 || len(version) == 0 {
+		http.Error(w, "no id or version", http.StatusBadRequest)
+
+		return
+	}
+
+	if err := configstore.Pin(r.Context(), id, version); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+
+		return
+	}
+
+	_, _ = w.Write([]byte("pinned"))
+}
+
+func handlerHistoryUnpin(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "only POST allowed", http.StatusMethodNotAllowed)
+
+		return
+	}
+
+	id := r.URL.Query().Get("id")
+
+	if len(id) == 0 {
+		http.Error(w, "no id", http.StatusBadRequest)
+
+		return
+	}
+
+	if err := configstore.Unpin(r.Context(), id); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+
+		return
+	}
+
+	_, _ = w.Write([]byte("unpinned"))
+}
@@ -18,8 +18,12 @@ import (
 	"io"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
+	"time"
 
+	alf "github.com/envoyproxy/go-control-plane/envoy/data/accesslog/v3"
+	"github.com/maksim-paskal/envoy-control-plane/pkg/accesslog"
 	"github.com/maksim-paskal/envoy-control-plane/pkg/web"
 )
 
@@ -117,3 +121,74 @@ func TestVersion(t *testing.T) {
 		t.Fatal("not correct response")
 	}
 }
+
+func TestNodes(t *testing.T) {
+	t.Parallel()
+
+	url := ts.URL + "/api/admin/nodes"
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	t.Log(string(body))
+
+	nodes := make([]map[string]interface{}, 0)
+
+	if err := json.Unmarshal(body, &nodes); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(nodes) != 0 {
+		t.Fatal("no envoys must be connected")
+	}
+}
+
+func TestAccessLogTail(t *testing.T) {
+	t.Parallel()
+
+	accesslog.Push(&accesslog.Entry{
+		Time:    time.Now(),
+		LogName: "test",
+		NodeID:  "web-tail-test-id",
+		HTTP: &alf.HTTPAccessLogEntry{
+			Request: &alf.HTTPRequestProperties{Authority: "test.local", Path: "/test"},
+		},
+	})
+
+	url := ts.URL + "/api/admin/accesslog/tail?follow=false&node=web-tail-test-id&authority=test.local"
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	t.Log(string(body))
+
+	if !strings.HasPrefix(string(body), "data: ") || strings.Count(string(body), "data: ") != 1 {
+		t.Fatal("not correct response")
+	}
+}